@@ -2,9 +2,9 @@
 package foo
 
 import (
-    "github.com/golang/protobuf/ptypes/timestamp"
+    "google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Repository interface {
-     GetFoo(timestamp *timestamp.Timestamp ) (*timestamp.Timestamp,  error)
+     GetFoo(timestamp *timestamppb.Timestamp ) (string,  error)
 }
\ No newline at end of file
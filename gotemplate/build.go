@@ -0,0 +1,110 @@
+package gotemplate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// buildMetadata describes the environment of the build, it is computed once per protoc invocation.
+type buildMetadata struct {
+	Date     time.Time
+	Hostname string
+	User     string
+	PWD      string
+	GoPWD    string
+	// GoModule is the path of the Go module containing the working directory, if any
+	GoModule    string
+	goModuleDir string
+}
+
+// newBuildMetadata inspects the environment of the build.
+// The build date honours SOURCE_DATE_EPOCH (https://reproducible-builds.org/specs/source-date-epoch/),
+// the deterministic mode omits the machine-specific metadata and defaults the build date to the unix epoch.
+func newBuildMetadata(deterministic bool) (*buildMetadata, error) {
+	build := &buildMetadata{Date: time.Now()}
+	if deterministic {
+		build.Date = time.Unix(0, 0).UTC()
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", epoch, err)
+		}
+		build.Date = time.Unix(seconds, 0).UTC()
+	}
+
+	pwd, _ := os.Getwd()
+	build.GoModule, build.goModuleDir = findGoModule(pwd)
+	if build.GoModule != "" {
+		build.GoPWD = build.goImportPath(pwd)
+	} else if os.Getenv("GOPATH") != "" {
+		build.GoPWD, _ = filepath.Rel(os.Getenv("GOPATH")+"/src", pwd)
+		if strings.Contains(build.GoPWD, "../") {
+			build.GoPWD = ""
+		}
+	}
+	if !deterministic {
+		build.Hostname, _ = os.Hostname()
+		build.User = os.Getenv("USER")
+		build.PWD = pwd
+	}
+	return build, nil
+}
+
+// goImportPath returns the import path of dir, relative to the working directory,
+// or an empty string if dir is not in the Go module.
+func (build *buildMetadata) goImportPath(dir string) string {
+	if build.GoModule == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(build.goModuleDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+	if rel == "." {
+		return build.GoModule
+	}
+	return path.Join(build.GoModule, filepath.ToSlash(rel))
+}
+
+// findGoModule returns the module path declared by the nearest go.mod, in dir or its parents,
+// and the directory of this go.mod.
+func findGoModule(dir string) (string, string) {
+	for {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			return parseGoModulePath(string(content)), dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// parseGoModulePath returns the path of the `module` directive of a go.mod file.
+func parseGoModulePath(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+				return unquoted
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,470 @@
+package gotemplate
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/plugin"
+	ggdescriptor "github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+
+	pgghelpers "github.com/moul/protoc-gen-gotemplate/helpers"
+)
+
+// templateScope defines how many times a template is rendered.
+type templateScope string
+
+const (
+	// scopeDefault templates are rendered once per service, or once per file with `all=true`
+	scopeDefault templateScope = ""
+	// scopeGlobal templates are rendered once per protoc invocation
+	scopeGlobal templateScope = "global"
+	// scopeFile templates are rendered once per file
+	scopeFile templateScope = "file"
+	// scopeService templates are rendered once per service
+	scopeService templateScope = "service"
+	// scopeMethod templates are rendered once per service method
+	scopeMethod templateScope = "method"
+	// scopeMessage templates are rendered once per message, including nested ones
+	scopeMessage templateScope = "message"
+	// scopeEnum templates are rendered once per enum, including nested ones
+	scopeEnum templateScope = "enum"
+)
+
+func parseTemplateScope(s string) (templateScope, error) {
+	switch scope := templateScope(s); scope {
+	case scopeGlobal, scopeFile, scopeService, scopeMethod, scopeMessage, scopeEnum:
+		return scope, nil
+	}
+	return scopeDefault, fmt.Errorf("invalid scope %q", s)
+}
+
+var (
+	messageScopeRe = regexp.MustCompile(`{{[^}]*\.Message\b`)
+	enumScopeRe    = regexp.MustCompile(`{{[^}]*\.CurrentEnum\b`)
+)
+
+// superTemplateName is the name of the template overridden by a template of a later template directory.
+const superTemplateName = "super"
+
+// overriddenTemplateName is the name of the template overridden level+1 times.
+func overriddenTemplateName(level int) string {
+	if level == 0 {
+		return superTemplateName
+	}
+	return fmt.Sprintf("%s.%d", superTemplateName, level)
+}
+
+// templateFile is a template found in the template directories.
+type templateFile struct {
+	name   string
+	path   string
+	dir    string
+	header *templateHeader
+	body   string
+	// overrides is the template of the same name in a previous template directory, if any
+	overrides *templateFile
+}
+
+type GenericTemplateBasedEncoder struct {
+	opts        *pluginOptions
+	request     *plugin_go.CodeGeneratorRequest
+	registry    *ggdescriptor.Registry
+	service     *descriptor.ServiceDescriptorProto
+	method      *descriptor.MethodDescriptorProto
+	file        *descriptor.FileDescriptorProto
+	enum        []*descriptor.EnumDescriptorProto
+	message     *descriptor.DescriptorProto
+	currentEnum *descriptor.EnumDescriptorProto
+	typeName    string
+	scope       templateScope
+}
+
+type Ast struct {
+	BuildDate               time.Time                          `json:"build-date"`
+	BuildHostname           string                             `json:"build-hostname"`
+	BuildUser               string                             `json:"build-user"`
+	GoPWD                   string                             `json:"go-pwd,omitempty"`
+	GoModule                string                             `json:"go-module,omitempty"`
+	GoDestinationImportPath string                             `json:"go-destination-import-path,omitempty"`
+	PWD                     string                             `json:"pwd"`
+	Debug                   bool                               `json:"debug"`
+	DestinationDir          string                             `json:"destination-dir"`
+	File                    *descriptor.FileDescriptorProto    `json:"file"`
+	RawFilename             string                             `json:"raw-filename"`
+	Filename                string                             `json:"filename"`
+	TemplateDir             string                             `json:"template-dir"`
+	TemplateDirs            []string                           `json:"template-dirs"`
+	Vars                    map[string]string                  `json:"vars,omitempty"`
+	Service                 *descriptor.ServiceDescriptorProto `json:"service"`
+	Method                  *descriptor.MethodDescriptorProto  `json:"method,omitempty"`
+	Enum                    []*descriptor.EnumDescriptorProto  `json:"enum"`
+	Message                 *descriptor.DescriptorProto        `json:"message,omitempty"`
+	CurrentEnum             *descriptor.EnumDescriptorProto    `json:"current-enum,omitempty"`
+	TypeName                string                             `json:"type-name,omitempty"`
+	ProtoFiles              []*descriptor.FileDescriptorProto  `json:"proto-files,omitempty"`
+	FilesToGenerate         []string                           `json:"files-to-generate,omitempty"`
+	Registry                *ggdescriptor.Registry             `json:"-"`
+	// AllEnums are the enums of the file, including the ones nested in messages
+	AllEnums []*pgghelpers.Enum `json:"-"`
+	// Model is the resolved view of the whole request, the other *Model fields are the resolved
+	// views of the current file, service, method, message and enum
+	Model        *pgghelpers.Model   `json:"-"`
+	FileModel    *pgghelpers.File    `json:"-"`
+	ServiceModel *pgghelpers.Service `json:"-"`
+	MethodModel  *pgghelpers.Method  `json:"-"`
+	MessageModel *pgghelpers.Message `json:"-"`
+	EnumModel    *pgghelpers.Enum    `json:"-"`
+}
+
+func newGenericServiceTemplateBasedEncoder(service *descriptor.ServiceDescriptorProto, file *descriptor.FileDescriptorProto, opts *pluginOptions) (e *GenericTemplateBasedEncoder) {
+	e = &GenericTemplateBasedEncoder{
+		service: service,
+		file:    file,
+		opts:    opts,
+		enum:    file.GetEnumType(),
+	}
+	return
+}
+
+func newGenericTemplateBasedEncoder(file *descriptor.FileDescriptorProto, opts *pluginOptions) (e *GenericTemplateBasedEncoder) {
+	e = &GenericTemplateBasedEncoder{
+		service: nil,
+		file:    file,
+		opts:    opts,
+		enum:    file.GetEnumType(),
+	}
+	return
+}
+
+func newGenericGlobalTemplateBasedEncoder(request *plugin_go.CodeGeneratorRequest, registry *ggdescriptor.Registry, opts *pluginOptions) (e *GenericTemplateBasedEncoder) {
+	e = &GenericTemplateBasedEncoder{
+		request:  request,
+		registry: registry,
+		scope:    scopeGlobal,
+		opts:     opts,
+	}
+	return
+}
+
+func newGenericMessageTemplateBasedEncoder(message *descriptor.DescriptorProto, typeName string, file *descriptor.FileDescriptorProto, opts *pluginOptions) (e *GenericTemplateBasedEncoder) {
+	e = &GenericTemplateBasedEncoder{
+		file:     file,
+		message:  message,
+		typeName: typeName,
+		scope:    scopeMessage,
+		opts:     opts,
+		enum:     file.GetEnumType(),
+	}
+	return
+}
+
+func newGenericEnumTemplateBasedEncoder(enum *descriptor.EnumDescriptorProto, typeName string, file *descriptor.FileDescriptorProto, opts *pluginOptions) (e *GenericTemplateBasedEncoder) {
+	e = &GenericTemplateBasedEncoder{
+		file:        file,
+		currentEnum: enum,
+		typeName:    typeName,
+		scope:       scopeEnum,
+		opts:        opts,
+		enum:        file.GetEnumType(),
+	}
+	return
+}
+
+func newGenericMethodTemplateBasedEncoder(method *descriptor.MethodDescriptorProto, service *descriptor.ServiceDescriptorProto, file *descriptor.FileDescriptorProto, opts *pluginOptions) (e *GenericTemplateBasedEncoder) {
+	e = &GenericTemplateBasedEncoder{
+		service: service,
+		method:  method,
+		file:    file,
+		scope:   scopeMethod,
+		opts:    opts,
+		enum:    file.GetEnumType(),
+	}
+	return
+}
+
+// withScope restricts the encoder to the templates declaring the given scope.
+func (e *GenericTemplateBasedEncoder) withScope(scope templateScope) *GenericTemplateBasedEncoder {
+	e.scope = scope
+	return e
+}
+
+// templateScopeOf returns the scope declared in the front-matter of a template,
+// or detects it from the fields its filename refers to.
+func templateScopeOf(tmpl *templateFile) templateScope {
+	switch {
+	case tmpl.header.Scope != scopeDefault:
+		return tmpl.header.Scope
+	case messageScopeRe.MatchString(tmpl.name):
+		return scopeMessage
+	case enumScopeRe.MatchString(tmpl.name):
+		return scopeEnum
+	default:
+		return scopeDefault
+	}
+}
+
+// templates returns the templates of the encoder scope.
+func (e *GenericTemplateBasedEncoder) templates() []*templateFile {
+	templates := []*templateFile{}
+	for _, tmpl := range e.opts.templates.templates {
+		if templateScopeOf(tmpl) != e.scope {
+			continue
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates
+}
+
+func (e *GenericTemplateBasedEncoder) genAst(tmpl *templateFile) (*Ast, error) {
+	// prepare the ast passed to the template engine
+	ast := Ast{
+		BuildDate:               e.opts.build.Date,
+		BuildHostname:           e.opts.build.Hostname,
+		BuildUser:               e.opts.build.User,
+		PWD:                     e.opts.build.PWD,
+		GoPWD:                   e.opts.build.GoPWD,
+		GoModule:                e.opts.build.GoModule,
+		GoDestinationImportPath: e.opts.build.goImportPath(e.opts.DestinationDir),
+		File:                    e.file,
+		TemplateDir:             tmpl.dir,
+		TemplateDirs:            e.opts.TemplateDirs,
+		DestinationDir:          e.opts.DestinationDir,
+		Vars:                    e.opts.Vars,
+		RawFilename:             tmpl.name,
+		Filename:                "",
+		Service:                 e.service,
+		Method:                  e.method,
+		Enum:                    e.enum,
+		Message:                 e.message,
+		CurrentEnum:             e.currentEnum,
+		TypeName:                e.typeName,
+	}
+	e.resolveModels(&ast)
+	if ast.FileModel != nil {
+		ast.AllEnums = ast.FileModel.AllEnums
+	}
+	if e.scope == scopeGlobal {
+		ast.ProtoFiles = e.request.GetProtoFile()
+		ast.FilesToGenerate = e.request.GetFileToGenerate()
+		ast.Registry = e.registry
+	}
+	filename := tmpl.name
+	if tmpl.header.Filename != "" {
+		filename = tmpl.header.Filename
+	}
+	buffer := new(bytes.Buffer)
+	t, err := template.New("").Funcs(pgghelpers.ProtoHelpersFuncMap).Parse(filename)
+	if err != nil {
+		return nil, e.newTemplateError(tmpl, "filename", err)
+	}
+	if err := t.Execute(buffer, ast); err != nil {
+		return nil, e.newTemplateError(tmpl, "filename", err)
+	}
+	ast.Filename = buffer.String()
+	return &ast, nil
+}
+
+// resolveModels sets the resolved views of the current elements in the ast.
+func (e *GenericTemplateBasedEncoder) resolveModels(ast *Ast) {
+	if model == nil {
+		return
+	}
+	ast.Model = model
+	if e.file == nil {
+		return
+	}
+	ast.FileModel = model.LookupFile(e.file.GetName())
+	if ast.FileModel == nil {
+		return
+	}
+	for _, service := range ast.FileModel.Services {
+		if service.ServiceDescriptorProto == e.service {
+			ast.ServiceModel = service
+			for _, method := range service.Methods {
+				if method.MethodDescriptorProto == e.method {
+					ast.MethodModel = method
+				}
+			}
+		}
+	}
+	if e.message != nil {
+		ast.MessageModel = model.LookupMessage(e.typeName)
+	}
+	if e.currentEnum != nil {
+		ast.EnumModel = model.LookupEnum(e.typeName)
+	}
+}
+
+// skip evaluates the skip predicate declared in the front-matter of a template.
+func (e *GenericTemplateBasedEncoder) skip(tmpl *templateFile, ast *Ast) (bool, error) {
+	predicate := tmpl.header.Skip
+	if predicate == "" {
+		return false, nil
+	}
+	if !strings.Contains(predicate, "{{") {
+		predicate = "{{" + predicate + "}}"
+	}
+	buffer := new(bytes.Buffer)
+	t, err := template.New("").Funcs(pgghelpers.ProtoHelpersFuncMap).Parse(predicate)
+	if err != nil {
+		return false, e.newTemplateError(tmpl, "skip", err)
+	}
+	if err := t.Execute(buffer, ast); err != nil {
+		return false, e.newTemplateError(tmpl, "skip", err)
+	}
+	result := strings.TrimSpace(buffer.String())
+	if result == "" || result == "<nil>" {
+		return false, nil
+	}
+	skip, err := strconv.ParseBool(result)
+	if err != nil {
+		return false, e.newTemplateError(tmpl, "skip", fmt.Errorf("expected a boolean, got %q", result))
+	}
+	return skip, nil
+}
+
+// buildContent renders a template, it returns nil if the template is skipped.
+func (e *GenericTemplateBasedEncoder) buildContent(tmpl *templateFile) (*plugin_go.CodeGeneratorResponse_File, error) {
+	// initialize template engine
+	templateName := filepath.Base(tmpl.name)
+	t, err := e.opts.templates.base.Clone()
+	if err != nil {
+		return nil, e.newTemplateError(tmpl, "", err)
+	}
+	t = t.New(templateName)
+	if err := e.parseOverridden(t, tmpl); err != nil {
+		return nil, err
+	}
+	if _, err := t.Parse(tmpl.body); err != nil {
+		return nil, e.newTemplateError(tmpl, "", err)
+	}
+
+	ast, err := e.genAst(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	if skip, err := e.skip(tmpl, ast); err != nil || skip {
+		return nil, err
+	}
+
+	// generate the content
+	buffer := new(bytes.Buffer)
+	if err := t.Execute(buffer, ast); err != nil {
+		return nil, e.newTemplateError(tmpl, "", err)
+	}
+
+	content := buffer.String()
+	filename := ast.Filename
+	if tmpl.header.Filename == "" {
+		filename = strings.TrimSuffix(filename, ".tmpl")
+	}
+	return &plugin_go.CodeGeneratorResponse_File{
+		Content: &content,
+		Name:    &filename,
+	}, nil
+}
+
+// parseOverridden parses the templates overridden by tmpl, the first directory first so that their blocks
+// are overridden by the ones of the later directories, `super` referring in each of them to the template it overrides.
+func (e *GenericTemplateBasedEncoder) parseOverridden(t *template.Template, tmpl *templateFile) error {
+	chain := []*templateFile{}
+	for overridden := tmpl.overrides; overridden != nil; overridden = overridden.overrides {
+		chain = append(chain, overridden)
+	}
+	for level := len(chain) - 1; level >= 0; level-- {
+		name := overriddenTemplateName(level)
+		if _, err := t.New(name).Parse(chain[level].body); err != nil {
+			return e.newTemplateError(chain[level], "", err)
+		}
+		for _, parsed := range t.Templates() {
+			if parsed.Tree != nil && parsed.Tree.ParseName == name {
+				renameTemplateCalls(parsed.Tree.Root, superTemplateName, overriddenTemplateName(level+1))
+			}
+		}
+	}
+	return nil
+}
+
+func renameTemplateCalls(node parse.Node, from, to string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			renameTemplateCalls(child, from, to)
+		}
+	case *parse.IfNode:
+		renameTemplateCalls(n.List, from, to)
+		renameTemplateCalls(n.ElseList, from, to)
+	case *parse.RangeNode:
+		renameTemplateCalls(n.List, from, to)
+		renameTemplateCalls(n.ElseList, from, to)
+	case *parse.WithNode:
+		renameTemplateCalls(n.List, from, to)
+		renameTemplateCalls(n.ElseList, from, to)
+	case *parse.TemplateNode:
+		if n.Name == from {
+			n.Name = to
+		}
+	}
+}
+
+// Files renders the templates of the encoder, it returns all the errors encountered.
+func (e *GenericTemplateBasedEncoder) Files() ([]*plugin_go.CodeGeneratorResponse_File, error) {
+	rendered, err := e.render()
+	files := make([]*plugin_go.CodeGeneratorResponse_File, 0, len(rendered))
+	for _, file := range rendered {
+		files = append(files, file.CodeGeneratorResponse_File)
+	}
+	return files, err
+}
+
+// render renders the templates of the encoder, it returns all the errors encountered.
+func (e *GenericTemplateBasedEncoder) render() ([]*renderedFile, error) {
+	templates := e.templates()
+
+	// render the templates concurrently, keeping the order of the templates
+	results := make([]*plugin_go.CodeGeneratorResponse_File, len(templates))
+	errors := make([]error, len(templates))
+	var wg sync.WaitGroup
+	for i, tmpl := range templates {
+		wg.Add(1)
+		go func(i int, tmpl *templateFile) {
+			defer wg.Done()
+			results[i], errors[i] = e.buildContent(tmpl)
+		}(i, tmpl)
+	}
+	wg.Wait()
+
+	files := make([]*renderedFile, 0, len(templates))
+	var errs errorList
+	for i, tmpl := range templates {
+		errs.add(errors[i])
+		source := e.templateSource(tmpl)
+		if e.opts.Debug && errors[i] == nil {
+			// i.e: `templates/server.go.tmpl: rendered to server.go (file="shop.proto" service="ShopService")`
+			source.Message = "skipped"
+			if results[i] != nil {
+				source.Message = "rendered to " + results[i].GetName()
+			}
+			log.Print(source)
+			source.Message = ""
+		}
+		if results[i] != nil {
+			files = append(files, &renderedFile{CodeGeneratorResponse_File: results[i], source: source})
+		}
+	}
+	return files, errs.err()
+}
@@ -0,0 +1,141 @@
+package gotemplate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// text/template errors look like `template: name:line:column: message`, the column being optional
+var templateErrorRe = regexp.MustCompile(`(?s)^template: ([^:]*):(\d+)(?::(\d+))?: (.*)$`)
+
+// templateError is an error raised while rendering a template, annotated with its location
+// and with the protobuf elements the template was rendered for.
+type templateError struct {
+	Template string
+	Line     int
+	Column   int
+	File     string
+	Service  string
+	Method   string
+	TypeName string
+	Message  string
+}
+
+func (e *templateError) Error() string {
+	location := e.Template
+	if e.Line > 0 {
+		location += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			location += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+
+	context := []string{}
+	if e.File != "" {
+		context = append(context, fmt.Sprintf("file=%q", e.File))
+	}
+	if e.Service != "" {
+		context = append(context, fmt.Sprintf("service=%q", e.Service))
+	}
+	if e.Method != "" {
+		context = append(context, fmt.Sprintf("method=%q", e.Method))
+	}
+	if e.TypeName != "" {
+		context = append(context, fmt.Sprintf("type=%q", e.TypeName))
+	}
+	if len(context) == 0 {
+		return fmt.Sprintf("%s: %s", location, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (%s)", location, e.Message, strings.Join(context, " "))
+}
+
+// newTemplateError annotates err with the template and the protobuf elements being rendered.
+// Errors raised by the body of the template are located using the line and column reported
+// by text/template, errors raised by a front-matter expression are prefixed by the key of the expression.
+func (e *GenericTemplateBasedEncoder) newTemplateError(tmpl *templateFile, key string, err error) *templateError {
+	tmplErr := e.templateSource(tmpl)
+	e.opts.templates.locate(tmplErr, tmpl, key, err)
+	return tmplErr
+}
+
+// locate sets the message of an error raised by tmpl, and its location if it is raised by the body of
+// tmpl, of a partial or of a template overridden by tmpl.
+func (set *templateSet) locate(tmplErr *templateError, tmpl *templateFile, key string, err error) {
+	tmplErr.Template = tmpl.path
+	tmplErr.Message = err.Error()
+	if match := templateErrorRe.FindStringSubmatch(err.Error()); match != nil {
+		tmplErr.Message = match[4]
+		if key == "" {
+			// the error may be raised by a partial or by the overridden template
+			for _, partial := range set.partials {
+				if partial.name == match[1] {
+					tmpl = partial
+				}
+			}
+			for level, overridden := 0, tmpl.overrides; overridden != nil; level, overridden = level+1, overridden.overrides {
+				if match[1] == overriddenTemplateName(level) {
+					tmpl = overridden
+					break
+				}
+			}
+			tmplErr.Template = tmpl.path
+			tmplErr.Line, _ = strconv.Atoi(match[2])
+			tmplErr.Line += tmpl.header.Lines
+			tmplErr.Column, _ = strconv.Atoi(match[3])
+		}
+	}
+	if key != "" {
+		tmplErr.Message = key + ": " + tmplErr.Message
+	}
+}
+
+// templateSource returns an error without message locating the template and the protobuf elements being rendered.
+func (e *GenericTemplateBasedEncoder) templateSource(tmpl *templateFile) *templateError {
+	return &templateError{
+		Template: tmpl.path,
+		File:     e.file.GetName(),
+		Service:  e.service.GetName(),
+		Method:   e.method.GetName(),
+		TypeName: e.typeName,
+	}
+}
+
+// errorList collects the errors raised while generating the files.
+type errorList []error
+
+func (l errorList) Error() string {
+	msgs := make([]string, 0, len(l))
+	for _, err := range l {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// add appends err to the list, flattening nested lists and ignoring duplicates.
+func (l *errorList) add(err error) {
+	if err == nil {
+		return
+	}
+	if list, ok := err.(errorList); ok {
+		for _, err := range list {
+			l.add(err)
+		}
+		return
+	}
+	for _, existing := range *l {
+		if existing.Error() == err.Error() {
+			return
+		}
+	}
+	*l = append(*l, err)
+}
+
+// err returns nil if the list is empty.
+func (l errorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -0,0 +1,177 @@
+package gotemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// renderedFile is a file rendered by a template, source locating the template and the protobuf
+// elements it was rendered for.
+type renderedFile struct {
+	*plugin_go.CodeGeneratorResponse_File
+	source *templateError
+}
+
+// output is a generated file, the concatenation of the files rendered with its name.
+type output struct {
+	file    *plugin_go.CodeGeneratorResponse_File
+	sources []*templateError
+	// lines are the first lines of the rendered files in the output
+	lines []int
+}
+
+func newOutput(file *renderedFile) *output {
+	return &output{file: file.CodeGeneratorResponse_File, sources: []*templateError{file.source}, lines: []int{1}}
+}
+
+func (o *output) append(file *renderedFile) {
+	o.sources = append(o.sources, file.source)
+	o.lines = append(o.lines, strings.Count(o.file.GetContent(), "\n")+1)
+	*o.file.Content += file.GetContent()
+}
+
+// formatters format the outputs after their extension, the YAML outputs being only trimmed, see trimYAML.
+var formatters = map[string]func(content string) (string, error){
+	".go":   formatGo,
+	".json": formatJSON,
+	".yaml": trimYAML,
+	".yml":  trimYAML,
+}
+
+// format formats the output after its extension, a syntax error being reported against the template
+// that rendered the erroneous line.
+func (o *output) format() error {
+	formatter := formatters[filepath.Ext(o.file.GetName())]
+	if formatter == nil || strings.TrimSpace(o.file.GetContent()) == "" {
+		return nil
+	}
+	content, err := formatter(o.file.GetContent())
+	if err == nil {
+		o.file.Content = &content
+		return nil
+	}
+
+	source, message := 0, fmt.Sprintf("format %s: %v", o.file.GetName(), err)
+	if syntaxErr, ok := err.(*syntaxError); ok {
+		for i, line := range o.lines {
+			if line <= syntaxErr.Line {
+				source = i
+			}
+		}
+		message = fmt.Sprintf("format %s:%s", o.file.GetName(), syntaxErr)
+	}
+	tmplErr := *o.sources[source]
+	tmplErr.Message = message
+	return &tmplErr
+}
+
+// syntaxError is a syntax error of an output, located by its 1-based line and column.
+type syntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// formatGo formats Go code as gofmt, the outputs without package clause being formatted as fragments.
+func formatGo(content string) (string, error) {
+	formatted, err := format.Source([]byte(content))
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		return "", &syntaxError{Line: list[0].Pos.Line, Column: list[0].Pos.Column, Message: list[0].Msg}
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+// formatJSON indents the JSON values of the content with two spaces, one value after the other.
+func formatJSON(content string) (string, error) {
+	buffer := new(bytes.Buffer)
+	decoder := json.NewDecoder(strings.NewReader(content))
+	for {
+		var value json.RawMessage
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return buffer.String(), nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			line, column := offsetPosition(content, len(content))
+			return "", &syntaxError{Line: line, Column: column, Message: "unexpected end of JSON input"}
+		}
+		if err == nil {
+			err = json.Indent(buffer, value, "", "  ")
+		}
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line, column := offsetPosition(content, int(syntaxErr.Offset))
+			return "", &syntaxError{Line: line, Column: column, Message: syntaxErr.Error()}
+		}
+		if err != nil {
+			return "", err
+		}
+		buffer.WriteString("\n")
+	}
+}
+
+// blockScalarIndicator matches the lines ending by the indicator of a literal or folded block scalar,
+// i.e: `key: |`, `- >-` or `--- |2 # comment`.
+var blockScalarIndicator = regexp.MustCompile(`(^|\s)[|>][1-9+-]{0,2}(\s+#.*)?$`)
+
+// trimYAML trims the whitespace of YAML documents: line endings, trailing spaces and blank lines
+// around the documents. The documents are not parsed: the content of the block scalars, the lines
+// indented deeper than a line ending by `|` or `>`, is kept as is, and only the lines indented by a
+// tab, forbidden by YAML, are reported.
+func trimYAML(content string) (string, error) {
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	// block is the indentation of the line introducing the current block scalar, -1 for a document
+	// start, or -2 outside of block scalars
+	block, keep := -2, false
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t")
+		indent := len(trimmed) - len(strings.TrimLeft(trimmed, " "))
+		if block > -2 && (trimmed == "" || indent > block) {
+			continue
+		}
+		block, keep = -2, false
+		lines[i] = trimmed
+		if strings.HasPrefix(trimmed, "\t") {
+			return "", &syntaxError{Line: i + 1, Column: 1, Message: "tab in indentation"}
+		}
+		if indicator := blockScalarIndicator.FindString(trimmed); indicator != "" && !strings.HasPrefix(trimmed[indent:], "#") {
+			block, keep = indent, strings.Contains(strings.SplitN(indicator, "#", 2)[0], "+")
+			if strings.HasPrefix(trimmed, "---") {
+				block = -1
+			}
+		}
+	}
+	formatted := strings.Join(lines, "\n")
+	if keep {
+		// the trailing line breaks of a `|+` or `>+` block scalar are part of its content
+		return strings.TrimLeft(formatted, "\n"), nil
+	}
+	return strings.Trim(formatted, "\n") + "\n", nil
+}
+
+// offsetPosition returns the 1-based line and column of the byte before offset.
+func offsetPosition(content string, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	if offset > 0 {
+		offset--
+	}
+	line := strings.Count(content[:offset], "\n") + 1
+	return line, offset - strings.LastIndexByte(content[:offset], '\n')
+}
@@ -0,0 +1,78 @@
+package gotemplate
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter func(string) (string, error)
+		content   string
+		formatted string
+	}{
+		{"go", formatGo, "package foo\nfunc  F( ) {return}", "package foo\n\nfunc F() { return }\n"},
+		{"go fragment", formatGo, "func  F( ) {return}", "func F() { return }"},
+		{"json", formatJSON, `{"a":[1,2],"b":{}}`, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n"},
+		{"json stream", formatJSON, "{\"a\":1}{\"b\":2}\n\n3", "{\n  \"a\": 1\n}\n{\n  \"b\": 2\n}\n3\n"},
+		{"yaml", trimYAML, "\n\r\na: 1   \r\nb: 2\t\n\n", "a: 1\nb: 2\n"},
+		{"yaml block scalars", trimYAML, "a: |\n  keep  \n\n  x \t\nb: >- # folded\n  y  \nc: 2  \n", "a: |\n  keep  \n\n  x \t\nb: >- # folded\n  y  \nc: 2\n"},
+		{"yaml document block scalar", trimYAML, "--- |\ntop  \n", "--- |\ntop  \n"},
+		{"yaml keep chomping", trimYAML, "a: |+\n  x\n\n\n", "a: |+\n  x\n\n\n"},
+		{"yaml comment", trimYAML, "# not a block |\n  x  \n", "# not a block |\n  x\n"},
+	}
+	for _, test := range tests {
+		formatted, err := test.formatter(test.content)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if formatted != test.formatted {
+			t.Errorf("%s: formatted %q, want %q", test.name, formatted, test.formatted)
+		}
+	}
+}
+
+func TestFormattersErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter func(string) (string, error)
+		content   string
+		err       string
+	}{
+		{"go", formatGo, "package foo\n\nfunc F() {\n\treturn x y\n}\n", "4:11: expected ';', found y"},
+		{"json", formatJSON, "{\"a\":1}\n{\"b\":,}", "2:6: invalid character ',' looking for beginning of value"},
+		{"json truncated", formatJSON, "{\"a\":1}\n{\"b\":", "2:5: unexpected end of JSON input"},
+		{"yaml tab", trimYAML, "a:\n\tb: 1\n", "2:1: tab in indentation"},
+		{"yaml tab after block scalar", trimYAML, "a: |\n  x\n\tb: 1\n", "3:1: tab in indentation"},
+	}
+	for _, test := range tests {
+		_, err := test.formatter(test.content)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	rendered := func(template, content string) *renderedFile {
+		return &renderedFile{
+			CodeGeneratorResponse_File: &plugin_go.CodeGeneratorResponse_File{Name: proto.String("out.json"), Content: proto.String(content)},
+			source:                     &templateError{Template: template, File: "shop.proto"},
+		}
+	}
+	out := newOutput(rendered("header.json.tmpl", "{\"a\":1}\n"))
+	out.append(rendered("body.json.tmpl", "{\"b\":,}\n"))
+	err := out.format()
+	want := `body.json.tmpl: format out.json:2:6: invalid character ',' looking for beginning of value (file="shop.proto")`
+	if err == nil || err.Error() != want {
+		t.Errorf("format() = %v, want %s", err, want)
+	}
+
+	out = newOutput(rendered("header.json.tmpl", "{\"a\":1}"))
+	out.append(rendered("body.json.tmpl", "{\"b\":2}"))
+	if err := out.format(); err != nil || out.file.GetContent() != "{\n  \"a\": 1\n}\n{\n  \"b\": 2\n}\n" {
+		t.Errorf("format() = %q, %v", out.file.GetContent(), err)
+	}
+}
@@ -0,0 +1,194 @@
+package gotemplate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// templateHeader is the optional front-matter header of a template.
+//
+// YAML headers are delimited by `---` lines and use `key: value` pairs,
+// TOML headers are delimited by `+++` lines and use `key = value` pairs:
+//
+//	---
+//	scope: message
+//	filename: models/{{.Message.Name | snakeCase}}.ts
+//	skip: not .Message.Field
+//	---
+type templateHeader struct {
+	// Scope overrides the scope detected from the template filename
+	Scope templateScope
+	// Filename is a template used instead of the template path to compute the output filename
+	Filename string
+	// Skip is a template expression, the template is not rendered when it evaluates to true
+	Skip string
+	// Lines is the number of lines of the header, delimiters included
+	Lines int
+}
+
+// parseFrontMatter splits the optional front-matter header from the body of a template.
+// A delimited block is a header as soon as it closes, its lines must then be front-matter keys, see
+// frontMatterKeys: the errors are templateErrors located by their line, the caller setting the template.
+// Unclosed blocks are kept in the body.
+func parseFrontMatter(content string) (*templateHeader, string, error) {
+	header := &templateHeader{}
+
+	var sep string
+	switch {
+	case strings.HasPrefix(content, "---\n"), strings.HasPrefix(content, "---\r\n"):
+		sep = ":"
+	case strings.HasPrefix(content, "+++\n"), strings.HasPrefix(content, "+++\r\n"):
+		sep = "="
+	default:
+		return header, content, nil
+	}
+
+	delimiter := content[:3]
+	lines := strings.SplitAfter(content, "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") == delimiter {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		// i.e: a YAML output starting with a document marker
+		return header, content, nil
+	}
+
+	found := make(map[string]bool)
+	for i := 1; i < end; i++ {
+		key, value, ok, err := parseKeyValue(lines[i], sep)
+		if err == nil && ok && (lines[i][0] == ' ' || lines[i][0] == '\t') {
+			err = fmt.Errorf("unexpected indentation")
+		}
+		if err == nil && ok && !frontMatterKeys[key] {
+			err = fmt.Errorf("unknown key %q, expected scope, filename or skip", key)
+		}
+		if err == nil && found[key] {
+			err = fmt.Errorf("duplicate key %q", key)
+		}
+		if err == nil && key == "scope" {
+			header.Scope, err = parseTemplateScope(value)
+		}
+		if err != nil {
+			return nil, "", &templateError{Line: i + 1, Message: fmt.Sprintf("front-matter: %v", err)}
+		}
+		if !ok {
+			continue
+		}
+		found[key] = true
+		switch key {
+		case "filename":
+			header.Filename = value
+		case "skip":
+			header.Skip = value
+		}
+	}
+	header.Lines = end + 1
+
+	return header, strings.Join(lines[end+1:], ""), nil
+}
+
+// frontMatterKeys are the keys of the front-matter header, see templateHeader.
+var frontMatterKeys = map[string]bool{"scope": true, "filename": true, "skip": true}
+
+// parseKeyValues parses flat `key<sep>value` lines, ignoring blank lines and `#` comments, see parseKeyValue.
+func parseKeyValues(lines []string, sep string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range lines {
+		key, value, ok, err := parseKeyValue(line, sep)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if !ok {
+			continue
+		}
+		if _, found := values[key]; found {
+			return nil, fmt.Errorf("line %d: duplicate key %q", i+1, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parseKeyValue parses a `key<sep>value` line, see parseScalar. ok is false for blank lines and `#` comments.
+func parseKeyValue(line string, sep string) (key string, value string, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, nil
+	}
+	parts := strings.SplitN(line, sep, 2)
+	if len(parts) != 2 {
+		return "", "", false, fmt.Errorf("expected `key%svalue`, got %q", sep, line)
+	}
+	key = strings.TrimSpace(parts[0])
+	if key == "" {
+		return "", "", false, fmt.Errorf("empty key")
+	}
+	if value, err = parseScalar(parts[1], sep); err != nil {
+		return "", "", false, fmt.Errorf("%s: %v", key, err)
+	}
+	return key, value, true, nil
+}
+
+// parseScalar parses the subset of YAML and TOML values supported by the front-matter headers and the
+// vars files: a single-line string, plain or quoted, followed by an optional `#` comment.
+// Double-quoted strings use the Go escapes, single-quoted strings escape a quote by doubling it, and the
+// TOML values must be quoted. Lists, maps, multi-line strings, anchors and tags are rejected.
+func parseScalar(value string, sep string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value[0] == '#' {
+		return "", nil
+	}
+
+	if value[0] == '"' || value[0] == '\'' {
+		end := closingQuote(value)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted string %s", value)
+		}
+		if rest := strings.TrimSpace(value[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after quoted string", rest)
+		}
+		if value[0] == '\'' {
+			return strings.Replace(value[1:end], "''", "'", -1), nil
+		}
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted string %s", value[:end+1])
+		}
+		return unquoted, nil
+	}
+
+	if sep == "=" {
+		return "", fmt.Errorf("TOML values must be quoted strings, got %s", value)
+	}
+	if strings.IndexByte("[]{},&*!|>%@`", value[0]) >= 0 || strings.HasPrefix(value, "- ") || strings.HasPrefix(value, "? ") {
+		return "", fmt.Errorf("unsupported value %s, only single-line strings are supported", value)
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	if strings.Contains(value, ": ") || strings.HasSuffix(value, ":") {
+		return "", fmt.Errorf("plain value %s cannot contain `: `, quote it", value)
+	}
+	return value, nil
+}
+
+// closingQuote returns the index of the quote closing the string value starts with, or -1.
+func closingQuote(value string) int {
+	quote := value[0]
+	for i := 1; i < len(value); i++ {
+		switch {
+		case quote == '"' && value[i] == '\\':
+			i++
+		case value[i] == quote && quote == '\'' && i+1 < len(value) && value[i+1] == '\'':
+			i++
+		case value[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,74 @@
+package gotemplate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		header  templateHeader
+		body    string
+	}{
+		{"no header", "package foo\n", templateHeader{}, "package foo\n"},
+		{
+			"yaml",
+			"---\nscope: message\nfilename: \"models/{{.Message.Name}}.ts\"\nskip: not .Message.Field # no fields\n---\nbody\n",
+			templateHeader{Scope: scopeMessage, Filename: "models/{{.Message.Name}}.ts", Skip: "not .Message.Field", Lines: 5},
+			"body\n",
+		},
+		{
+			"toml",
+			"+++\r\n# the scope\r\nscope = 'method'\r\n\r\n+++\r\nbody",
+			templateHeader{Scope: scopeMethod, Lines: 5},
+			"body",
+		},
+		{"empty header", "---\n---\n---\nkind: A\n", templateHeader{Lines: 2}, "---\nkind: A\n"},
+		{"unclosed block", "---\nkind: A\n", templateHeader{}, "---\nkind: A\n"},
+		{"marker in the body", "kind: A\n---\nkind: B\n", templateHeader{}, "kind: A\n---\nkind: B\n"},
+		{"quoted values", `---` + "\n" + `filename: 'it''s {{.File.Name}}'` + "\n" + `skip: "eq .Vars.x \"a\\tb\""` + "\n---\n", templateHeader{Filename: "it's {{.File.Name}}", Skip: `eq .Vars.x "a\tb"`, Lines: 4}, ""},
+	}
+	for _, test := range tests {
+		header, body, err := parseFrontMatter(test.content)
+		if err != nil {
+			t.Errorf("%s: parseFrontMatter: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*header, test.header) || body != test.body {
+			t.Errorf("%s: parseFrontMatter = %+v, %q, want %+v, %q", test.name, *header, body, test.header, test.body)
+		}
+	}
+}
+
+func TestParseFrontMatterErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		line    int
+		message string
+	}{
+		{"---\n# typo below\nscopee: message\n---\n", 3, `unknown key "scopee"`},
+		{"---\napiVersion: v1\nkind: Pod\n---\nkind: Pod\n", 2, `unknown key "apiVersion"`},
+		{"---\nscope: message\n  skip: true\n---\n", 3, "unexpected indentation"},
+		{"---\nscope: message\nscope: enum\n---\n", 3, `duplicate key "scope"`},
+		{"---\nscope: messages\n---\n", 2, `invalid scope "messages"`},
+		{"---\nfilename: [a, b]\n---\n", 2, "unsupported value [a, b]"},
+		{"---\nskip: a: b\n---\n", 2, "cannot contain `: `"},
+		{"---\n- scope\n---\n", 2, "expected `key:value`"},
+		{"+++\nscope = message\n+++\n", 2, "TOML values must be quoted strings"},
+		{"---\nfilename: \"x\n---\n", 2, "unterminated quoted string"},
+	}
+	for _, test := range tests {
+		_, _, err := parseFrontMatter(test.content)
+		tmplErr, ok := err.(*templateError)
+		if !ok {
+			t.Errorf("parseFrontMatter(%q) = %v, want a templateError", test.content, err)
+			continue
+		}
+		if tmplErr.Line != test.line || !strings.Contains(tmplErr.Message, test.message) {
+			t.Errorf("parseFrontMatter(%q) = line %d: %s, want line %d: %s", test.content, tmplErr.Line, tmplErr.Message, test.line, test.message)
+		}
+	}
+}
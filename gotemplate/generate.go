@@ -0,0 +1,208 @@
+// Package gotemplate renders Go templates against the protobuf files of a protoc request,
+// it is the library behind protoc-gen-gotemplate.
+package gotemplate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+	"github.com/golang/protobuf/protoc-gen-go/plugin"
+	ggdescriptor "github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+
+	pgghelpers "github.com/moul/protoc-gen-gotemplate/helpers"
+)
+
+var (
+	registry *ggdescriptor.Registry // some helpers need access to registry
+	model    *pgghelpers.Model      // resolved view of the request, built once
+)
+
+// Generate renders the templates for a request given by protoc, the parameters of the request being
+// the ones of protoc-gen-gotemplate. The template directories are looked up in templates, i.e: an
+// `embed.FS`, or in the OS file system if templates is nil. The errors are reported in the response.
+// Generate is not safe for concurrent use, the helpers sharing the model of the request, and as
+// protoc-gen-go, the generation of the protobufs exits the process on failure.
+func Generate(req *plugin_go.CodeGeneratorRequest, templates fs.FS) *plugin_go.CodeGeneratorResponse {
+	g := generator.New()
+	g.Request = req
+	g.Response.XXX_unrecognized = featureProto3Optional
+
+	if len(g.Request.FileToGenerate) == 0 {
+		g.Response.Error = proto.String("no files to generate")
+		return g.Response
+	}
+
+	// Parse parameters
+	opts, err := parseOptions(g.Request.GetParameter())
+	if err != nil {
+		g.Response.Error = proto.String(err.Error())
+		return g.Response
+	}
+	if opts.Help {
+		fmt.Fprint(os.Stderr, optionsUsage())
+		return g.Response
+	}
+	opts.templatesFS = templates
+	if opts.templates, err = loadTemplateSet(opts); err != nil {
+		g.Response.Error = proto.String(err.Error())
+		return g.Response
+	}
+	g.CommandLineParameters(strings.Join(opts.GeneratorParameters, ","))
+
+	outputs := make(map[string]*output)
+	concatOrAppend := func(file *renderedFile) {
+		if out, ok := outputs[file.GetName()]; ok {
+			out.append(file)
+		} else {
+			outputs[file.GetName()] = newOutput(file)
+			g.Response.File = append(g.Response.File, file.CodeGeneratorResponse_File)
+		}
+	}
+
+	model = pgghelpers.NewModel(g.Request)
+	pgghelpers.SetModel(model)
+
+	var errs errorList
+	generate := func(encoder *GenericTemplateBasedEncoder) {
+		files, err := encoder.render()
+		errs.add(err)
+		for _, file := range files {
+			concatOrAppend(file)
+		}
+	}
+
+	registry = ggdescriptor.NewRegistry()
+	pgghelpers.SetRegistry(registry)
+	if err := registry.Load(registryRequest(g.Request, opts)); err != nil {
+		errs.add(fmt.Errorf("registry: failed to load the request: %v", err))
+	}
+
+	// Generate the encoders
+	for _, file := range g.Request.GetProtoFile() {
+		if opts.All {
+			if _, err := registry.LookupFile(file.GetName()); err != nil {
+				errs.add(fmt.Errorf("registry: failed to lookup file %q: %v", file.GetName(), err))
+				continue
+			}
+			generate(newGenericTemplateBasedEncoder(file, opts))
+
+			continue
+		}
+
+		for _, service := range file.GetService() {
+			generate(newGenericServiceTemplateBasedEncoder(service, file, opts))
+		}
+	}
+
+	// Generate the scoped encoders
+	for _, file := range g.Request.GetProtoFile() {
+		if !isFileToGenerate(g.Request, file.GetName()) {
+			continue
+		}
+		encoders := []*GenericTemplateBasedEncoder{
+			newGenericTemplateBasedEncoder(file, opts).withScope(scopeFile),
+		}
+		for _, service := range file.GetService() {
+			encoders = append(encoders, newGenericServiceTemplateBasedEncoder(service, file, opts).withScope(scopeService))
+			for _, method := range service.GetMethod() {
+				encoders = append(encoders, newGenericMethodTemplateBasedEncoder(method, service, file, opts))
+			}
+		}
+		walkFile(file, func(message *descriptor.DescriptorProto, typeName string) {
+			encoders = append(encoders, newGenericMessageTemplateBasedEncoder(message, typeName, file, opts))
+		}, func(enum *descriptor.EnumDescriptorProto, typeName string) {
+			encoders = append(encoders, newGenericEnumTemplateBasedEncoder(enum, typeName, file, opts))
+		})
+		for _, encoder := range encoders {
+			generate(encoder)
+		}
+	}
+
+	// Generate the global encoder
+	generate(newGenericGlobalTemplateBasedEncoder(g.Request, registry, opts))
+
+	if opts.Format {
+		for _, file := range g.Response.File {
+			errs.add(outputs[file.GetName()].format())
+		}
+	}
+
+	if err := errs.err(); err != nil {
+		// report the errors to protoc instead of the generated files
+		g.Response.File = nil
+		g.Response.Error = proto.String(err.Error())
+	} else {
+		sort.SliceStable(g.Response.File, func(i, j int) bool {
+			return g.Response.File[i].GetName() < g.Response.File[j].GetName()
+		})
+
+		// Generate the protobufs
+		g.GenerateAllFiles()
+	}
+
+	return g.Response
+}
+
+// Usage describes the parameters supported by Generate.
+func Usage() string {
+	return optionsUsage()
+}
+
+// featureProto3Optional is the CodeGeneratorResponse.supported_features flag telling protoc
+// that the plugin supports the proto3 optional fields, encoded as an unrecognized field.
+var featureProto3Optional = append(proto.EncodeVarint(2<<3|proto.WireVarint), 1)
+
+// registryRequest returns the request to load in the registry.
+// The registry refuses to load files to generate from different packages, so outside of
+// single-package-mode only the messages and enums of every file are loaded, not the services.
+func registryRequest(req *plugin_go.CodeGeneratorRequest, opts *pluginOptions) *plugin_go.CodeGeneratorRequest {
+	if opts.SinglePackageMode {
+		return req
+	}
+	return &plugin_go.CodeGeneratorRequest{
+		Parameter: req.Parameter,
+		ProtoFile: req.ProtoFile,
+	}
+}
+
+func isFileToGenerate(req *plugin_go.CodeGeneratorRequest, name string) bool {
+	for _, f := range req.GetFileToGenerate() {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFile calls onMessage and onEnum for every message and enum of the file, nested ones included.
+func walkFile(file *descriptor.FileDescriptorProto, onMessage func(*descriptor.DescriptorProto, string), onEnum func(*descriptor.EnumDescriptorProto, string)) {
+	prefix := ""
+	if file.GetPackage() != "" {
+		prefix = "." + file.GetPackage()
+	}
+	for _, enum := range file.GetEnumType() {
+		onEnum(enum, prefix+"."+enum.GetName())
+	}
+	walkMessages(file.GetMessageType(), prefix, onMessage, onEnum)
+}
+
+func walkMessages(messages []*descriptor.DescriptorProto, prefix string, onMessage func(*descriptor.DescriptorProto, string), onEnum func(*descriptor.EnumDescriptorProto, string)) {
+	for _, message := range messages {
+		if message.GetOptions().GetMapEntry() {
+			// the synthetic entries of map fields are not rendered
+			continue
+		}
+		typeName := prefix + "." + message.GetName()
+		onMessage(message, typeName)
+		for _, enum := range message.GetEnumType() {
+			onEnum(enum, typeName+"."+enum.GetName())
+		}
+		walkMessages(message.GetNestedType(), typeName, onMessage, onEnum)
+	}
+}
@@ -0,0 +1,323 @@
+package gotemplate
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// pluginOptions holds the parameters given to the plugin, i.e: `--gotemplate_out=debug=true,all=true:.`
+type pluginOptions struct {
+	// TemplateDirs are searched in order, a template overriding the templates of the same path in the previous directories
+	TemplateDirs      []string
+	PartialsDir       string
+	DestinationDir    string
+	Debug             bool
+	All               bool
+	SinglePackageMode bool
+	Help              bool
+	// Vars are the user-defined template variables, exposed as `.Vars`
+	Vars          map[string]string
+	VarsFile      string
+	Deterministic bool
+	// Format enables the formatting of the outputs after their extension, see formatters
+	Format bool
+	// GeneratorParameters are forwarded to the protoc-gen-go generator
+	GeneratorParameters []string
+
+	build *buildMetadata
+	// templatesFS holds the template directories instead of the OS file system if set
+	templatesFS fs.FS
+	templates   *templateSet
+}
+
+type optionKind string
+
+const (
+	boolOption   optionKind = "bool"
+	stringOption optionKind = "string"
+	// listOption parameters can be given more than once, their default value is used if they are not given
+	listOption optionKind = "list"
+)
+
+// optionSpec declares a supported parameter.
+type optionSpec struct {
+	name        string
+	kind        optionKind
+	value       string
+	description string
+	// prefix options match every parameter starting with their name, i.e: `M<file>`
+	prefix bool
+	set    func(opts *pluginOptions, key string, value string) error
+}
+
+var optionSpecs = []optionSpec{
+	{
+		name:        "template_dir",
+		kind:        listOption,
+		value:       "./templates",
+		description: "path to look for templates, or to a .zip, .tar or .tar.gz archive of templates, can be given more than once, the templates of a directory overriding the ones of the same path in the previous directories",
+		set: func(opts *pluginOptions, _, value string) error {
+			opts.TemplateDirs = append(opts.TemplateDirs, value)
+			return nil
+		},
+	}, {
+		name:        "partials_dir",
+		kind:        stringOption,
+		description: "path to look for partials, or to an archive of partials, parsed with every template but never rendered, like the `_partials` and `_lib` directories of template_dir",
+		set:         func(opts *pluginOptions, _, value string) error { opts.PartialsDir = value; return nil },
+	}, {
+		name:        "destination_dir",
+		kind:        stringOption,
+		value:       ".",
+		description: "base path to write output",
+		set:         func(opts *pluginOptions, _, value string) error { opts.DestinationDir = value; return nil },
+	}, {
+		name:        "single-package-mode",
+		kind:        boolOption,
+		value:       "false",
+		description: "if true, protoc won't accept multiple packages to be compiled at once, and the services are also loaded in the registry",
+		set:         boolSetter(func(opts *pluginOptions, value bool) { opts.SinglePackageMode = value }),
+	}, {
+		name:        "debug",
+		kind:        boolOption,
+		value:       "false",
+		description: "if true, a more verbose output is generated",
+		set:         boolSetter(func(opts *pluginOptions, value bool) { opts.Debug = value }),
+	}, {
+		name:        "all",
+		kind:        boolOption,
+		value:       "false",
+		description: "if true, protobuf files without Service will also be parsed",
+		set:         boolSetter(func(opts *pluginOptions, value bool) { opts.All = value }),
+	}, {
+		name:        "deterministic",
+		kind:        boolOption,
+		value:       "false",
+		description: "if true, the build hostname, user and pwd are omitted and the build date defaults to the unix epoch, SOURCE_DATE_EPOCH is always honoured",
+		set:         boolSetter(func(opts *pluginOptions, value bool) { opts.Deterministic = value }),
+	}, {
+		name:        "format",
+		kind:        boolOption,
+		value:       "false",
+		description: "if true, the .go and .json outputs are formatted and the .yaml outputs trimmed, their syntax errors failing the generation",
+		set:         boolSetter(func(opts *pluginOptions, value bool) { opts.Format = value }),
+	}, {
+		name:        "help",
+		kind:        boolOption,
+		value:       "false",
+		description: "if true, the supported parameters are printed and nothing is generated",
+		set:         boolSetter(func(opts *pluginOptions, value bool) { opts.Help = value }),
+	}, {
+		name:        "var.",
+		kind:        stringOption,
+		description: "var.<key>=<value>, user-defined template variable exposed as .Vars.<key>",
+		prefix:      true,
+		set: func(opts *pluginOptions, key, value string) error {
+			opts.Vars[strings.TrimPrefix(key, "var.")] = value
+			return nil
+		},
+	}, {
+		name:        "vars_file",
+		kind:        stringOption,
+		description: "path to a YAML file of `key: value` template variables, overridden by var.<key> parameters",
+		set:         func(opts *pluginOptions, _, value string) error { opts.VarsFile = value; return nil },
+	}, {
+		name:        "import_prefix",
+		kind:        stringOption,
+		description: "forwarded to protoc-gen-go",
+		set:         generatorSetter,
+	}, {
+		name:        "import_path",
+		kind:        stringOption,
+		description: "forwarded to protoc-gen-go",
+		set:         generatorSetter,
+	}, {
+		name:        "plugins",
+		kind:        stringOption,
+		description: "forwarded to protoc-gen-go",
+		set:         generatorSetter,
+	}, {
+		name:        "M",
+		kind:        stringOption,
+		description: "M<file>=<go-package>, forwarded to protoc-gen-go",
+		prefix:      true,
+		set:         generatorSetter,
+	},
+}
+
+func boolSetter(set func(opts *pluginOptions, value bool)) func(*pluginOptions, string, string) error {
+	return func(opts *pluginOptions, _, value string) error {
+		b, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		set(opts, b)
+		return nil
+	}
+}
+
+func generatorSetter(opts *pluginOptions, key, value string) error {
+	if strings.Contains(value, ",") {
+		return fmt.Errorf("protoc-gen-go parameters cannot contain a comma")
+	}
+	opts.GeneratorParameters = append(opts.GeneratorParameters, key+"="+value)
+	return nil
+}
+
+func lookupOptionSpec(key string) *optionSpec {
+	for i, spec := range optionSpecs {
+		if (!spec.prefix && spec.name == key) || (spec.prefix && strings.HasPrefix(key, spec.name) && len(key) > len(spec.name)) {
+			return &optionSpecs[i]
+		}
+	}
+	return nil
+}
+
+// parseOptions parses the comma-separated `key=value` parameters given by protoc.
+// Commas, equal signs and backslashes can be escaped with a backslash, i.e: `\,` for a comma in a value.
+// A boolean parameter without value is set to true.
+func parseOptions(parameter string) (*pluginOptions, error) {
+	opts := &pluginOptions{Vars: make(map[string]string)}
+	seen := make(map[string]bool)
+	for _, parts := range splitParameters(parameter) {
+		key := parts[0]
+		if key == "" && len(parts) == 1 {
+			continue
+		}
+		spec := lookupOptionSpec(key)
+		if spec == nil {
+			return nil, fmt.Errorf("unknown parameter %q, supported parameters are: %s", key, strings.Join(optionNames(), ", "))
+		}
+		if len(parts) != 2 {
+			if spec.kind != boolOption {
+				return nil, fmt.Errorf("invalid parameter %q: expected %s=<%s>", key, key, spec.kind)
+			}
+			parts = append(parts, "true")
+		}
+		if seen[key] && spec.kind != listOption {
+			return nil, fmt.Errorf("parameter %q is set more than once", key)
+		}
+		seen[key] = true
+		if err := spec.set(opts, key, parts[1]); err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %v", key, err)
+		}
+	}
+	for _, spec := range optionSpecs {
+		if spec.value != "" && !seen[spec.name] {
+			if err := spec.set(opts, spec.name, spec.value); err != nil {
+				return nil, fmt.Errorf("default value of %q: %v", spec.name, err)
+			}
+		}
+	}
+	if err := opts.loadVarsFile(); err != nil {
+		return nil, err
+	}
+	build, err := newBuildMetadata(opts.Deterministic)
+	if err != nil {
+		return nil, err
+	}
+	opts.build = build
+	return opts, nil
+}
+
+// loadVarsFile adds the variables of the vars file that are not already set by a parameter.
+func (opts *pluginOptions) loadVarsFile() error {
+	if opts.VarsFile == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(opts.VarsFile)
+	if err != nil {
+		return fmt.Errorf("cannot read vars_file: %v", err)
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	// the directives and the marker starting the document are blanked, keeping the line numbers of the errors
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "%") {
+			lines[i] = ""
+			continue
+		}
+		if line == "---" || strings.HasPrefix(line, "--- #") {
+			lines[i] = ""
+		}
+		break
+	}
+	vars, err := parseKeyValues(lines, ":")
+	if err != nil {
+		return fmt.Errorf("%s: %v", opts.VarsFile, err)
+	}
+	for key, value := range vars {
+		if _, found := opts.Vars[key]; !found {
+			opts.Vars[key] = value
+		}
+	}
+	return nil
+}
+
+// splitParameters splits the parameters around unescaped commas, and each parameter
+// into a key and an optional value around the first unescaped equal sign.
+// `\,`, `\=` and `\\` are unescaped, other backslashes are kept as is.
+func splitParameters(s string) [][]string {
+	params := [][]string{}
+	parts := []string{}
+	current := new(bytes.Buffer)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`,=\`, s[i+1]) >= 0:
+			i++
+			current.WriteByte(s[i])
+		case c == '=' && len(parts) == 0:
+			parts = append(parts, current.String())
+			current.Reset()
+		case c == ',':
+			params = append(params, append(parts, current.String()))
+			parts = []string{}
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(params, append(parts, current.String()))
+}
+
+func optionNames() []string {
+	names := make([]string, 0, len(optionSpecs))
+	for _, spec := range optionSpecs {
+		name := spec.name
+		if spec.prefix {
+			name += "*"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// optionsUsage describes the supported parameters.
+func optionsUsage() string {
+	buffer := new(bytes.Buffer)
+	fmt.Fprintln(buffer, "Usage: protoc --gotemplate_out=[<key>=<value>,...:]<output-dir> <proto-files>")
+	fmt.Fprintln(buffer)
+	fmt.Fprintln(buffer, "Parameters are separated by commas, use `\\,`, `\\=` and `\\\\` to escape commas, equal signs and backslashes.")
+	fmt.Fprintln(buffer)
+	w := tabwriter.NewWriter(buffer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PARAMETER\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, spec := range optionSpecs {
+		name := spec.name
+		if spec.prefix {
+			name += "<...>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, spec.kind, spec.value, spec.description)
+	}
+	w.Flush()
+	return buffer.String()
+}
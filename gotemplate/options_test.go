@@ -0,0 +1,123 @@
+package gotemplate
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitParameters(t *testing.T) {
+	tests := []struct {
+		parameter string
+		params    [][]string
+	}{
+		{"", [][]string{{""}}},
+		{"debug", [][]string{{"debug"}}},
+		{"debug=true,all", [][]string{{"debug", "true"}, {"all"}}},
+		{`var.list=a\,b,var.eq=a\=b=c`, [][]string{{"var.list", "a,b"}, {"var.eq", "a=b=c"}}},
+		{`var.path=C:\\dir\n`, [][]string{{"var.path", `C:\dir\n`}}},
+		{`var.trailing=a\`, [][]string{{"var.trailing", `a\`}}},
+		{"template_dir=a,,template_dir=b", [][]string{{"template_dir", "a"}, {""}, {"template_dir", "b"}}},
+	}
+	for _, test := range tests {
+		if params := splitParameters(test.parameter); !reflect.DeepEqual(params, test.params) {
+			t.Errorf("splitParameters(%q) = %q, want %q", test.parameter, params, test.params)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		parameter string
+		check     func(opts *pluginOptions) bool
+		err       string
+	}{
+		{
+			parameter: "",
+			check: func(opts *pluginOptions) bool {
+				return reflect.DeepEqual(opts.TemplateDirs, []string{"./templates"}) && opts.DestinationDir == "." && !opts.Debug && !opts.Format
+			},
+		},
+		{
+			parameter: "template_dir=base,template_dir=override.tar.gz,destination_dir=out",
+			check: func(opts *pluginOptions) bool {
+				return reflect.DeepEqual(opts.TemplateDirs, []string{"base", "override.tar.gz"}) && opts.DestinationDir == "out"
+			},
+		},
+		{
+			parameter: "debug,all=TRUE,format=false,single-package-mode=1",
+			check: func(opts *pluginOptions) bool {
+				return opts.Debug && opts.All && !opts.Format && opts.SinglePackageMode
+			},
+		},
+		{
+			parameter: `var.company=ACME\, Inc.,var.query=a\=b`,
+			check: func(opts *pluginOptions) bool {
+				return reflect.DeepEqual(opts.Vars, map[string]string{"company": "ACME, Inc.", "query": "a=b"})
+			},
+		},
+		{
+			parameter: "Mfoo.proto=example.com/foo,plugins=grpc",
+			check: func(opts *pluginOptions) bool {
+				return reflect.DeepEqual(opts.GeneratorParameters, []string{"Mfoo.proto=example.com/foo", "plugins=grpc"})
+			},
+		},
+		{parameter: "template-dir=templates", err: `unknown parameter "template-dir"`},
+		{parameter: "var.=x", err: `unknown parameter "var."`},
+		{parameter: "destination_dir", err: `invalid parameter "destination_dir": expected destination_dir=<string>`},
+		{parameter: "debug=yes", err: `invalid value for "debug": expected true or false, got "yes"`},
+		{parameter: "destination_dir=a,destination_dir=b", err: `parameter "destination_dir" is set more than once`},
+		{parameter: `plugins=grpc\,foo`, err: "protoc-gen-go parameters cannot contain a comma"},
+		{parameter: "vars_file=does-not-exist.yaml", err: "cannot read vars_file"},
+	}
+	for _, test := range tests {
+		opts, err := parseOptions(test.parameter)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseOptions(%q) = %v, want an error containing %q", test.parameter, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("parseOptions(%q): %v", test.parameter, err)
+		case !test.check(opts):
+			t.Errorf("parseOptions(%q) = %+v", test.parameter, opts)
+		}
+	}
+}
+
+func TestLoadVarsFile(t *testing.T) {
+	tests := []struct {
+		content string
+		vars    map[string]string
+		err     string
+	}{
+		{content: "name: shop\nowner: 'ACME, Inc.'\n", vars: map[string]string{"name": "shop", "owner": "ACME, Inc.", "set": "param"}},
+		{content: "---\nname: shop # the service\n", vars: map[string]string{"name": "shop", "set": "param"}},
+		{content: "# vars\n%YAML 1.2\n--- # document\n\nname: shop\nset: file\n", vars: map[string]string{"name": "shop", "set": "param"}},
+		{content: "", vars: map[string]string{"set": "param"}},
+		{content: "---\nname: shop\n---\nname: other\n", err: "line 3: expected `key:value`"},
+		{content: "name: shop\n---\n", err: "line 2: expected `key:value`"},
+		{content: "---\nnames:\n  - shop\n", err: "line 3: expected `key:value`"},
+		{content: "name: [shop]\n", err: "line 1: name: unsupported value [shop]"},
+	}
+	for _, test := range tests {
+		file := filepath.Join(t.TempDir(), "vars.yaml")
+		if err := ioutil.WriteFile(file, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts := &pluginOptions{VarsFile: file, Vars: map[string]string{"set": "param"}}
+		err := opts.loadVarsFile()
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("loadVarsFile(%q) = %v, want an error containing %q", test.content, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("loadVarsFile(%q): %v", test.content, err)
+		case !reflect.DeepEqual(opts.Vars, test.vars):
+			t.Errorf("loadVarsFile(%q) = %v, want %v", test.content, opts.Vars, test.vars)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package gotemplate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// templateDirFS returns the file system of a template or partials directory: the directory itself,
+// or the content of a `.zip`, `.tar` or `.tar.gz` archive. The directories are looked up in the
+// templates file system given to Generate, or in the OS file system.
+func (opts *pluginOptions) templateDirFS(dir string) (fs.FS, error) {
+	var fsys fs.FS
+	var err error
+	switch {
+	case opts.templatesFS == nil && !isArchive(dir):
+		fsys = os.DirFS(dir)
+		if _, err = os.Stat(dir); err != nil {
+			return nil, err
+		}
+	case opts.templatesFS == nil:
+		var data []byte
+		if data, err = ioutil.ReadFile(dir); err == nil {
+			fsys, err = openArchive(dir, data)
+		}
+	default:
+		name := path.Clean(filepath.ToSlash(dir))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("%s: not a path of the templates file system", dir)
+		}
+		if !isArchive(name) {
+			if _, err = fs.Stat(opts.templatesFS, name); err == nil {
+				fsys, err = fs.Sub(opts.templatesFS, name)
+			}
+			break
+		}
+		var data []byte
+		if data, err = fs.ReadFile(opts.templatesFS, name); err == nil {
+			fsys, err = openArchive(dir, data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
+
+func isArchive(name string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// openArchive returns the content of an archive, its root being the template directory.
+func openArchive(name string, data []byte) (fs.FS, error) {
+	if strings.HasSuffix(name, ".zip") {
+		fsys, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return fsys, nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if !strings.HasSuffix(name, ".tar") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		r = gz
+	}
+	fsys := memFS{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		// the directories are implied by the paths of the files, the links are not followed
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		file := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if !fs.ValidPath(file) {
+			return nil, fmt.Errorf("%s: invalid path %q", name, header.Name)
+		}
+		if fsys[file], err = ioutil.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", name, file, err)
+		}
+	}
+}
+
+// memFS is a read-only file system of the files of a tar archive, indexed by their path.
+type memFS map[string][]byte
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, found := m[name]; found {
+		return &memFile{Reader: bytes.NewReader(data), info: memFileInfo{name: path.Base(name), size: int64(len(data))}}, nil
+	}
+	entries, err := m.ReadDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memDir{info: memFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// ReadDir returns the entries of a directory, sorted by name.
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	found := make(map[string]fs.DirEntry)
+	for file, data := range m {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		rest := file[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			found[rest[:i]] = fs.FileInfoToDirEntry(memFileInfo{name: rest[:i], dir: true})
+		} else {
+			found[rest] = fs.FileInfoToDirEntry(memFileInfo{name: rest, size: int64(len(data))})
+		}
+	}
+	if len(found) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(found))
+	for _, entry := range found {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.dir }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+func (i memFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    memFileInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next n entries of the directory, or all the remaining ones if n <= 0.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 || n >= len(d.entries) {
+		if n > 0 && len(d.entries) == 0 {
+			return nil, io.EOF
+		}
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
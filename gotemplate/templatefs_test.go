@@ -0,0 +1,152 @@
+package gotemplate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// archiveFiles are the files of the test archives, the directories being implied by their paths.
+var archiveFiles = map[string]string{
+	"./server.go.tmpl":               "package {{.File.Package}}\n",
+	"_partials/header.tmpl":          "{{define \"header\"}}// generated{{end}}",
+	"docs/{{.Service.Name}}.md.tmpl": "# {{.Service.Name}}\n",
+}
+
+func zipArchive(t *testing.T) []byte {
+	buffer := new(bytes.Buffer)
+	w := zip.NewWriter(buffer)
+	for name, content := range archiveFiles {
+		f, err := w.Create(fsPath(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// tarArchive returns a tar archive of the files, with a directory entry and a symlink that are skipped.
+func tarArchive(t *testing.T, extra ...*tar.Header) []byte {
+	buffer := new(bytes.Buffer)
+	w := tar.NewWriter(buffer)
+	headers := append([]*tar.Header{
+		{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "link.tmpl", Typeflag: tar.TypeSymlink, Linkname: "server.go.tmpl"},
+	}, extra...)
+	for _, header := range headers {
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range archiveFiles {
+		if err := w.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipArchive(t *testing.T, data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	w := gzip.NewWriter(buffer)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func fsPath(name string) string {
+	if len(name) > 2 && name[:2] == "./" {
+		return name[2:]
+	}
+	return name
+}
+
+func TestOpenArchive(t *testing.T) {
+	tgz := gzipArchive(t, tarArchive(t))
+	archives := map[string][]byte{
+		"templates.zip":    zipArchive(t),
+		"templates.tar":    tarArchive(t),
+		"templates.tar.gz": tgz,
+		"templates.tgz":    tgz,
+	}
+	expected := []string{}
+	for name := range archiveFiles {
+		expected = append(expected, fsPath(name))
+	}
+	for name, data := range archives {
+		fsys, err := openArchive(name, data)
+		if err != nil {
+			t.Errorf("openArchive(%q): %v", name, err)
+			continue
+		}
+		if err := fstest.TestFS(fsys, expected...); err != nil {
+			t.Errorf("openArchive(%q): %v", name, err)
+		}
+		for file, content := range archiveFiles {
+			if data, err := fs.ReadFile(fsys, fsPath(file)); err != nil || string(data) != content {
+				t.Errorf("openArchive(%q): %s = %q, %v, want %q", name, file, data, err, content)
+			}
+		}
+		if _, err := fs.Stat(fsys, "link.tmpl"); err == nil {
+			t.Errorf("openArchive(%q): the symlink is not skipped", name)
+		}
+	}
+}
+
+func TestOpenArchiveErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"corrupted.zip":    []byte("not a zip"),
+		"corrupted.tar.gz": []byte("not a gzip"),
+		"truncated.tar":    tarArchive(t)[:700],
+		"escaping.tar":     tarArchive(t, &tar.Header{Name: "../escape.tmpl", Typeflag: tar.TypeReg, Mode: 0644}),
+	}
+	for name, data := range tests {
+		if _, err := openArchive(name, data); err == nil {
+			t.Errorf("openArchive(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestTemplateDirFS(t *testing.T) {
+	opts := &pluginOptions{templatesFS: fstest.MapFS{
+		"templates/server.go.tmpl":    {Data: []byte("package {{.File.Package}}\n")},
+		"packs/templates-v1.2.tar.gz": {Data: gzipArchive(t, tarArchive(t))},
+	}}
+	tests := []struct {
+		dir  string
+		file string
+	}{
+		{"templates", "server.go.tmpl"},
+		{"./templates/", "server.go.tmpl"},
+		{"packs/templates-v1.2.tar.gz", "docs/{{.Service.Name}}.md.tmpl"},
+	}
+	for _, test := range tests {
+		fsys, err := opts.templateDirFS(test.dir)
+		if err != nil {
+			t.Errorf("templateDirFS(%q): %v", test.dir, err)
+			continue
+		}
+		if _, err := fs.Stat(fsys, test.file); err != nil {
+			t.Errorf("templateDirFS(%q): %v", test.dir, err)
+		}
+	}
+	for _, dir := range []string{"missing", "../templates", "/templates", "packs/missing.zip"} {
+		if _, err := opts.templateDirFS(dir); err == nil {
+			t.Errorf("templateDirFS(%q) succeeded, want an error", dir)
+		}
+	}
+}
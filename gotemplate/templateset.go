@@ -0,0 +1,155 @@
+package gotemplate
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	pgghelpers "github.com/moul/protoc-gen-gotemplate/helpers"
+)
+
+// partialDirs are the directories of the template directory holding partials instead of templates.
+var partialDirs = map[string]bool{"_partials": true, "_lib": true}
+
+// templateSet holds the templates of the template directories, loaded once per generation and
+// filtered by scope by every encoder.
+type templateSet struct {
+	templates []*templateFile
+	// partials are parsed with every template, see base
+	partials []*templateFile
+	// base holds the parsed partials, cloned for every rendered template
+	base *template.Template
+}
+
+// loadTemplateSet loads the templates and the partials parsed with every template: the ones of
+// `partials_dir` first, then the ones of the `_partials` and `_lib` directories of the template directories.
+// A template of a template directory overrides the template of the same path of the previous directories.
+func loadTemplateSet(opts *pluginOptions) (*templateSet, error) {
+	set := &templateSet{}
+	if err := set.walk(opts); err != nil {
+		if tmplErr, ok := err.(*templateError); ok {
+			return nil, tmplErr
+		}
+		return nil, fmt.Errorf("cannot get templates from %q: %v", opts.TemplateDirs, err)
+	}
+
+	if opts.Debug {
+		for _, tmpl := range set.templates {
+			scope := templateScopeOf(tmpl)
+			if scope == scopeDefault {
+				scope = "default"
+			}
+			if tmpl.overrides != nil {
+				log.Printf("new template: %q from %q, scope %s, overriding %q", tmpl.name, tmpl.dir, scope, tmpl.overrides.path)
+			} else {
+				log.Printf("new template: %q from %q, scope %s", tmpl.name, tmpl.dir, scope)
+			}
+		}
+	}
+
+	set.base = template.New("").Funcs(pgghelpers.ProtoHelpersFuncMap)
+	for _, partial := range set.partials {
+		if opts.Debug {
+			log.Printf("new partial: %q", partial.path)
+		}
+		if _, err := set.base.New(partial.name).Parse(partial.body); err != nil {
+			tmplErr := &templateError{}
+			set.locate(tmplErr, partial, "", err)
+			return nil, tmplErr
+		}
+	}
+	return set, nil
+}
+
+func (set *templateSet) walk(opts *pluginOptions) error {
+	if opts.PartialsDir != "" {
+		fsys, err := opts.templateDirFS(opts.PartialsDir)
+		if err != nil {
+			return err
+		}
+		if err := set.loadPartials(fsys, ".", opts.PartialsDir); err != nil {
+			return err
+		}
+	}
+
+	index := make(map[string]int)
+	for _, dir := range opts.TemplateDirs {
+		fsys, err := opts.templateDirFS(dir)
+		if err != nil {
+			return err
+		}
+		err = fs.WalkDir(fsys, ".", func(rel string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			file := filepath.Join(dir, filepath.FromSlash(rel))
+			if entry.IsDir() {
+				switch {
+				case opts.PartialsDir != "" && file == filepath.Clean(opts.PartialsDir):
+					return fs.SkipDir
+				case partialDirs[entry.Name()] && rel != ".":
+					if err := set.loadPartials(fsys, rel, file); err != nil {
+						return err
+					}
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(rel) != ".tmpl" {
+				return nil
+			}
+			content, err := fs.ReadFile(fsys, rel)
+			if err != nil {
+				return err
+			}
+			header, body, err := parseFrontMatter(string(content))
+			if tmplErr, ok := err.(*templateError); ok {
+				tmplErr.Template = file
+				return tmplErr
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			rel = filepath.FromSlash(rel)
+			tmpl := &templateFile{name: rel, path: file, dir: dir, header: header, body: body}
+			if i, overrides := index[rel]; overrides {
+				tmpl.overrides, set.templates[i] = set.templates[i], tmpl
+				return nil
+			}
+			index[rel] = len(set.templates)
+			set.templates = append(set.templates, tmpl)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPartials adds the `.tmpl` files of the root directory of fsys to the partials, named after their path
+// prefixed by dir.
+func (set *templateSet) loadPartials(fsys fs.FS, root string, dir string) error {
+	return fs.WalkDir(fsys, root, func(rel string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(rel) != ".tmpl" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, rel)
+		if err != nil {
+			return err
+		}
+		if root != "." {
+			rel = strings.TrimPrefix(rel, root+"/")
+		}
+		name := filepath.Join(dir, filepath.FromSlash(rel))
+		set.partials = append(set.partials, &templateFile{name: name, path: name, header: &templateHeader{}, body: string(content)})
+		return nil
+	})
+}
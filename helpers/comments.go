@@ -0,0 +1,127 @@
+package pgghelpers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Comments are the comments attached to an element in the .proto source.
+type Comments struct {
+	Leading  string
+	Trailing string
+	// Detached are the comments separated from the element by a blank line
+	Detached []string
+}
+
+// field numbers of the descriptors, used to locate an element in SourceCodeInfo
+const (
+	filePackagePath   = 2
+	fileMessagePath   = 4
+	fileEnumPath      = 5
+	fileServicePath   = 6
+	messageFieldPath  = 2
+	messageNestedPath = 3
+	messageEnumPath   = 4
+	messageOneofPath  = 8
+	enumValuePath     = 2
+	serviceMethodPath = 2
+)
+
+// sourceComments indexes the comments of a file by location path.
+type sourceComments map[string]Comments
+
+func newSourceComments(fd *descriptor.FileDescriptorProto) sourceComments {
+	comments := make(sourceComments)
+	for _, loc := range fd.GetSourceCodeInfo().GetLocation() {
+		if loc.LeadingComments == nil && loc.TrailingComments == nil && len(loc.LeadingDetachedComments) == 0 {
+			continue
+		}
+		comments[pathKey(loc.GetPath())] = Comments{
+			Leading:  loc.GetLeadingComments(),
+			Trailing: loc.GetTrailingComments(),
+			Detached: loc.GetLeadingDetachedComments(),
+		}
+	}
+	return comments
+}
+
+func (c sourceComments) get(path []int32) Comments {
+	return c[pathKey(path)]
+}
+
+func pathKey(path []int32) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(parts, ".")
+}
+
+// appendPath returns a new path, to avoid sharing the backing array between siblings.
+func appendPath(path []int32, elems ...int32) []int32 {
+	p := make([]int32, 0, len(path)+len(elems))
+	return append(append(p, path...), elems...)
+}
+
+// commentLines splits a comment in lines, removing the indentation common to all the lines
+// and the leading and trailing blank lines.
+func commentLines(comment string) []string {
+	lines := strings.Split(strings.Trim(comment, "\n"), "\n")
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
+
+// goComment formats a comment as Go `//` comment lines.
+func goComment(comment string) string {
+	lines := commentLines(comment)
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = "//"
+		} else {
+			lines[i] = "// " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// jsComment formats a comment as a JSDoc `/** */` block.
+func jsComment(comment string) string {
+	lines := commentLines(comment)
+	if len(lines) == 0 {
+		return ""
+	}
+	for i, line := range lines {
+		line = strings.Replace(line, "*/", "*\\/", -1)
+		if line == "" {
+			lines[i] = " *"
+		} else {
+			lines[i] = " * " + line
+		}
+	}
+	return "/**\n" + strings.Join(lines, "\n") + "\n */"
+}
+
+// markdownComment formats a comment as Markdown text.
+func markdownComment(comment string) string {
+	return strings.Join(commentLines(comment), "\n")
+}
@@ -0,0 +1,279 @@
+package pgghelpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+	ggdescriptor "github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
+)
+
+// wellKnownGoPackages are the `go_package` options of the well-known types,
+// used for the descriptors sent without them by older versions of protoc.
+var wellKnownGoPackages = map[string]string{
+	"google/protobuf/any.proto":            "github.com/golang/protobuf/ptypes/any",
+	"google/protobuf/api.proto":            "google.golang.org/genproto/protobuf/api;api",
+	"google/protobuf/descriptor.proto":     "github.com/golang/protobuf/protoc-gen-go/descriptor",
+	"google/protobuf/duration.proto":       "github.com/golang/protobuf/ptypes/duration",
+	"google/protobuf/empty.proto":          "github.com/golang/protobuf/ptypes/empty",
+	"google/protobuf/field_mask.proto":     "google.golang.org/genproto/protobuf/field_mask;field_mask",
+	"google/protobuf/source_context.proto": "google.golang.org/genproto/protobuf/source_context;source_context",
+	"google/protobuf/struct.proto":         "github.com/golang/protobuf/ptypes/struct;structpb",
+	"google/protobuf/timestamp.proto":      "github.com/golang/protobuf/ptypes/timestamp",
+	"google/protobuf/type.proto":           "google.golang.org/genproto/protobuf/ptype;ptype",
+	"google/protobuf/wrappers.proto":       "github.com/golang/protobuf/ptypes/wrappers",
+}
+
+func goPackageOption(f *descriptor.FileDescriptorProto) string {
+	if gopkg := f.GetOptions().GetGoPackage(); gopkg != "" {
+		return gopkg
+	}
+	return wellKnownGoPackages[f.GetName()]
+}
+
+// goImportAlias returns the name to use to import the Go package generated for the file.
+// It is the package name, suffixed when two packages of the request have the same name.
+func goImportAlias(f *descriptor.FileDescriptorProto) string {
+	if model != nil {
+		if alias, found := model.goAliases[f.GetName()]; found {
+			return alias
+		}
+	}
+	return goPackageName(f)
+}
+
+// goAliases assigns an import alias to every file, the files to generate being served first.
+func goAliases(files []*File, fileToGenerate []string) map[string]string {
+	aliases := make(map[string]string)
+	paths := make(map[string]string) // alias -> import path
+	reserve := func(f *File) {
+		if _, found := aliases[f.GetName()]; found {
+			return
+		}
+		name, path := goPackageName(f.FileDescriptorProto), goPackagePath(f.FileDescriptorProto)
+		alias := name
+		for i := 0; paths[alias] != "" && paths[alias] != path; i++ {
+			alias = fmt.Sprintf("%s_%d", name, i)
+		}
+		paths[alias] = path
+		aliases[f.GetName()] = alias
+	}
+	for _, name := range fileToGenerate {
+		for _, f := range files {
+			if f.GetName() == name {
+				reserve(f)
+			}
+		}
+	}
+	for _, f := range files {
+		reserve(f)
+	}
+	return aliases
+}
+
+// goTypeName returns the name of the Go type generated for a message or an enum, i.e: `Outer_Inner`.
+func goTypeName(typeName string) string {
+	if model != nil {
+		if msg := model.LookupMessage(typeName); msg != nil {
+			return goMessageName(msg)
+		}
+		if enum := model.LookupEnum(typeName); enum != nil {
+			return goEnumName(enum)
+		}
+	}
+	return generator.CamelCase(shortType(typeName))
+}
+
+// FQMN returns the fully qualified name of the message with a leading dot, i.e: `.package.Outer.Inner`.
+func (msg *Message) FQMN() string {
+	return "." + msg.FullName
+}
+
+// GoPkg returns the Go package generated for the file, as the `.GoPkg` of the files of the registry.
+// The alias is only set when the package name is taken by another package of the request.
+func (f *File) GoPkg() ggdescriptor.GoPackage {
+	pkg := ggdescriptor.GoPackage{Path: goPackagePath(f.FileDescriptorProto), Name: goPackageName(f.FileDescriptorProto)}
+	if alias := goImportAlias(f.FileDescriptorProto); alias != pkg.Name {
+		pkg.Alias = alias
+	}
+	return pkg
+}
+
+// GoType returns the name of the Go type generated for the message, prefixed by the import alias of its
+// package unless it is generated in the package of import path currentPackage.
+func (msg *Message) GoType(currentPackage string) string {
+	if goPackagePath(msg.File.FileDescriptorProto) == currentPackage {
+		return goMessageName(msg)
+	}
+	return goImportAlias(msg.File.FileDescriptorProto) + "." + goMessageName(msg)
+}
+
+func goMessageName(msg *Message) string {
+	names := []string{}
+	for ; msg != nil; msg = msg.Parent {
+		names = append([]string{msg.GetName()}, names...)
+	}
+	return generator.CamelCaseSlice(names)
+}
+
+// FQEN returns the fully qualified name of the enum with a leading dot, i.e: `.package.Outer.Enum`.
+func (enum *Enum) FQEN() string {
+	return "." + enum.FullName
+}
+
+func goEnumName(enum *Enum) string {
+	if enum.Parent == nil {
+		return generator.CamelCase(enum.GetName())
+	}
+	return goMessageName(enum.Parent) + "_" + generator.CamelCase(enum.GetName())
+}
+
+// goFieldType returns the Go type of a field, the messages and enums being prefixed by qualifier.
+func goFieldType(f *descriptor.FieldDescriptorProto, qualifier func(typeName string) string) string {
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		key, value := entry.Fields[0].FieldDescriptorProto, entry.Fields[1].FieldDescriptorProto
+		return fmt.Sprintf("map[%s]%s", strings.TrimPrefix(goFieldType(key, qualifier), "*"), goMapValueType(value, qualifier))
+	}
+
+	var typ string
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		typ = "float64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		typ = "float32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64:
+		typ = "int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		typ = "uint64"
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32:
+		typ = "int32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		typ = "uint32"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		typ = "bool"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		typ = "string"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		typ = "[]byte"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		typ = qualifier(f.GetTypeName()) + goTypeName(f.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return goRepeated(f, "*"+qualifier(f.GetTypeName())+goTypeName(f.GetTypeName()))
+	default:
+		return "interface{}"
+	}
+	if goFieldIsPointer(f) {
+		return "*" + typ
+	}
+	return goRepeated(f, typ)
+}
+
+// goMapValueType returns the type of a map value, only messages are pointers.
+func goMapValueType(f *descriptor.FieldDescriptorProto, qualifier func(typeName string) string) string {
+	typ := goFieldType(f, qualifier)
+	if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return typ
+	}
+	return strings.TrimPrefix(typ, "*")
+}
+
+func goRepeated(f *descriptor.FieldDescriptorProto, typ string) string {
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return "[]" + typ
+	}
+	return typ
+}
+
+// goFieldIsPointer reports whether a scalar or enum field is generated as a pointer,
+// which is the case of the proto3 optional fields and of the singular fields of proto2 messages outside of a oneof.
+func goFieldIsPointer(f *descriptor.FieldDescriptorProto) bool {
+	switch {
+	case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED, f.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return false
+	case isFieldProto3Optional(f):
+		return true
+	case f.OneofIndex != nil:
+		return false
+	}
+	field := model.lookupField(f)
+	return field != nil && field.Message.File.GetSyntax() != "proto3"
+}
+
+// mapEntry returns the synthetic entry message of a map field, or nil.
+func mapEntry(f *descriptor.FieldDescriptorProto) *Message {
+	if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || model == nil {
+		return nil
+	}
+	msg := model.LookupMessage(f.GetTypeName())
+	if msg == nil || !msg.GetOptions().GetMapEntry() {
+		return nil
+	}
+	return msg
+}
+
+// goTypeImport returns the import path of the Go package declaring the type of a message or enum field,
+// or an empty string for the other fields.
+func goTypeImport(f *descriptor.FieldDescriptorProto) string {
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		return goTypeImport(entry.Fields[1].FieldDescriptorProto)
+	}
+	if file := declaringFile(f.GetTypeName()); file != nil {
+		return goPackagePath(file.FileDescriptorProto)
+	}
+	return ""
+}
+
+func declaringFile(typeName string) *File {
+	if model == nil || typeName == "" {
+		return nil
+	}
+	if msg := model.LookupMessage(typeName); msg != nil {
+		return msg.File
+	}
+	if enum := model.LookupEnum(typeName); enum != nil {
+		return enum.File
+	}
+	return nil
+}
+
+// goOneofWrapper returns the name of the Go struct wrapping a oneof field, i.e: `Product_Name`,
+// or an empty string if the field is not part of a oneof.
+func goOneofWrapper(f *descriptor.FieldDescriptorProto) string {
+	field := model.lookupField(f)
+	if field == nil || !isFieldOneof(f) {
+		return ""
+	}
+	msg := field.Message
+	name := goMessageName(msg) + "_" + generator.CamelCase(f.GetName())
+	// protoc-gen-go appends underscores until the name does not collide with a nested type
+	for collides := true; collides; {
+		collides = false
+		for _, nested := range msg.Messages {
+			collides = collides || goMessageName(nested) == name
+		}
+		for _, enum := range msg.Enums {
+			collides = collides || goEnumName(enum) == name
+		}
+		if collides {
+			name += "_"
+		}
+	}
+	return name
+}
+
+// goOneofInterface returns the name of the Go interface implemented by the wrappers of a oneof,
+// i.e: `isProduct_Kind`, or an empty string if the field is not part of a oneof.
+func goOneofInterface(f *descriptor.FieldDescriptorProto) string {
+	field := model.lookupField(f)
+	if field == nil || field.Oneof == nil {
+		return ""
+	}
+	return "is" + goMessageName(field.Message) + "_" + generator.CamelCase(field.Oneof.GetName())
+}
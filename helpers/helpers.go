@@ -3,16 +3,15 @@ package pgghelpers
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"regexp"
 	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig"
-	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	ggdescriptor "github.com/grpc-ecosystem/grpc-gateway/protoc-gen-grpc-gateway/descriptor"
 	"github.com/huandu/xstrings"
-	options "google.golang.org/genproto/googleapis/api/annotations"
 )
 
 var jsReservedRe *regexp.Regexp = regexp.MustCompile(`(^|[^A-Za-z])(do|if|in|for|let|new|try|var|case|else|enum|eval|false|null|this|true|void|with|break|catch|class|const|super|throw|while|yield|delete|export|import|public|return|static|switch|typeof|default|extends|finally|package|private|continue|debugger|function|arguments|interface|protected|implements|instanceof)($|[^A-Za-z])`)
@@ -87,21 +86,65 @@ var ProtoHelpersFuncMap = template.FuncMap{
 	"snakeCase":               xstrings.ToSnakeCase,
 	"getProtoFile":            getProtoFile,
 	"getMessageType":          getMessageType,
+	"getEnum":                 getEnum,
+	"getEnumByTypeName":       getEnumByTypeName,
+	"getOption":               getOption,
+	"getExtension":            getExtension,
 	"getEnumValue":            getEnumValue,
 	"isFieldMessage":          isFieldMessage,
 	"isFieldMessageTimeStamp": isFieldMessageTimeStamp,
 	"isFieldRepeated":         isFieldRepeated,
+	"isFieldMap":              isFieldMap,
+	"isFieldOneof":            isFieldOneof,
+	"isFieldProto3Optional":   isFieldProto3Optional,
+	"getOneof":                getOneof,
+	"mapKeyField":             mapKeyField,
+	"mapValueField":           mapValueField,
 	"haskellType":             haskellType,
 	"goType":                  goType,
 	"goTypeWithPackage":       goTypeWithPackage,
+	"goPackagePath":           goPackagePath,
+	"goPackageName":           goPackageName,
+	"goImportAlias":           goImportAlias,
+	"goTypeImport":            goTypeImport,
+	"goOneofWrapper":          goOneofWrapper,
+	"goOneofInterface":        goOneofInterface,
 	"jsType":                  jsType,
+	"tsType":                  tsType,
+	"tsTypeWithNamespace":     tsTypeWithNamespace,
+	"tsTypeName":              tsTypeName,
+	"tsOptional":              tsOptional,
+	"tsField":                 tsField,
+	"tsEnumUnion":             tsEnumUnion,
+	"fieldType":               fieldType,
+	"pythonType":              pythonType,
+	"rustType":                rustType,
+	"kotlinType":              kotlinType,
+	"swiftType":               swiftType,
+	"javaType":                javaType,
 	"jsSuffixReserved":        jsSuffixReservedKeyword,
 	"namespacedFlowType":      namespacedFlowType,
 	"httpVerb":                httpVerb,
 	"httpPath":                httpPath,
 	"httpBody":                httpBody,
+	"httpRules":               httpRules,
+	"hasHttpRule":             hasHttpRule,
+	"httpPathTemplate":        parsePathTemplate,
+	"httpPathVariables":       httpPathVariables,
+	"pathVariableField":       pathVariableField,
+	"expressPath":             expressPath,
+	"muxPath":                 muxPath,
+	"openapiPath":             openapiPath,
+	"printfPath":              printfPath,
+	"methodKind":              methodKind,
+	"methodsByKind":           methodsByKind,
+	"unaryMethods":            unaryMethods,
+	"streamingMethods":        streamingMethods,
 	"shortType":               shortType,
 	"urlHasVarsFromMessage":   urlHasVarsFromMessage,
+	"goComment":               goComment,
+	"jsComment":               jsComment,
+	"markdownComment":         markdownComment,
 }
 
 func init() {
@@ -121,27 +164,31 @@ func getProtoFile(name string) *ggdescriptor.File {
 	return file
 }
 
-func getMessageType(f *descriptor.FileDescriptorProto, name string) *ggdescriptor.Message {
-	if registry != nil {
-		msg, err := registry.LookupMsg(".", name)
-		if err != nil {
-			panic(err)
-		}
-		return msg
+// getMessageType resolves a message type name, i.e: the TypeName of a field, across all the files of the request.
+// Fully qualified names start with a dot (`.package.Outer.Inner`), other names are resolved from the package of f.
+// It returns nil if the message cannot be found.
+func getMessageType(f *descriptor.FileDescriptorProto, name string) *Message {
+	if model == nil {
+		return nil
 	}
+	return model.resolveMessage(f.GetPackage(), name)
+}
 
-	// name is in the form .packageName.MessageTypeName.InnerMessageTypeName...
-	// e.g. .article.ProductTag
-	splits := strings.Split(name, ".")
-	target := splits[len(splits)-1]
-	for _, m := range f.MessageType {
-		if target == *m.Name {
-			return &ggdescriptor.Message{
-				DescriptorProto: m,
-			}
-		}
+// getEnum resolves the enum of an enum field across all the files of the request, or returns nil.
+func getEnum(f *descriptor.FieldDescriptorProto) *Enum {
+	if f.GetType() != descriptor.FieldDescriptorProto_TYPE_ENUM {
+		return nil
 	}
-	return nil
+	return getEnumByTypeName(nil, f.GetTypeName())
+}
+
+// getEnumByTypeName resolves an enum type name across all the files of the request, or returns nil.
+// Fully qualified names start with a dot (`.package.Message.Enum`), other names are resolved from the package of f.
+func getEnumByTypeName(f *descriptor.FileDescriptorProto, name string) *Enum {
+	if model == nil {
+		return nil
+	}
+	return model.resolveEnum(f.GetPackage(), name)
 }
 
 func getEnumValue(f []*descriptor.EnumDescriptorProto, name string) []*descriptor.EnumValueDescriptorProto {
@@ -171,147 +218,87 @@ func isFieldMessage(f *descriptor.FieldDescriptorProto) bool {
 	return false
 }
 
+// isFieldRepeated reports whether a field is repeated, map fields excluded.
 func isFieldRepeated(f *descriptor.FieldDescriptorProto) bool {
 	if f.Type != nil && f.Label != nil && *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-		return true
+		return !isFieldMap(f)
 	}
 
 	return false
 }
 
-func goTypeWithPackage(f *descriptor.FieldDescriptorProto) string {
-	pkg := ""
-	if *f.Type == descriptor.FieldDescriptorProto_TYPE_MESSAGE || *f.Type == descriptor.FieldDescriptorProto_TYPE_ENUM {
-		pkg = getPackageTypeName(*f.TypeName)
+// isFieldMap reports whether a field is a map, i.e: a repeated field of a synthetic `<Field>Entry` message.
+func isFieldMap(f *descriptor.FieldDescriptorProto) bool {
+	return mapEntry(f) != nil
+}
+
+// mapKeyField returns the key field of a map field, or nil.
+func mapKeyField(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		return entry.Fields[0].FieldDescriptorProto
 	}
-	return goType(pkg, f)
+	return nil
 }
 
-func haskellType(pkg string, f *descriptor.FieldDescriptorProto) string {
-	switch *f.Type {
-	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Float]"
-		}
-		return "Float"
-	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Float]"
-		}
-		return "Float"
-	case descriptor.FieldDescriptorProto_TYPE_INT64:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Int64]"
-		}
-		return "Int64"
-	case descriptor.FieldDescriptorProto_TYPE_UINT64:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Word]"
-		}
-		return "Word"
-	case descriptor.FieldDescriptorProto_TYPE_INT32:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Int]"
-		}
-		return "Int"
-	case descriptor.FieldDescriptorProto_TYPE_UINT32:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Word]"
-		}
-		return "Word"
-	case descriptor.FieldDescriptorProto_TYPE_BOOL:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Bool]"
-		}
-		return "Bool"
-	case descriptor.FieldDescriptorProto_TYPE_STRING:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Text]"
-		}
-		return "Text"
-	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		if pkg != "" {
-			pkg = pkg + "."
-		}
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return fmt.Sprintf("[%s%s]", pkg, shortType(*f.TypeName))
-		}
-		return fmt.Sprintf("%s%s", pkg, shortType(*f.TypeName))
-	case descriptor.FieldDescriptorProto_TYPE_BYTES:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[Word8]"
+// mapValueField returns the value field of a map field, or nil.
+func mapValueField(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		return entry.Fields[1].FieldDescriptorProto
+	}
+	return nil
+}
+
+// goTypeWithPackage is like goType, the messages and enums being prefixed by the import alias of their package.
+func goTypeWithPackage(f *descriptor.FieldDescriptorProto) string {
+	return goFieldType(f, func(typeName string) string {
+		if pkg := getPackageTypeName(typeName); pkg != "" {
+			return pkg + "."
 		}
-		return "Word8"
-	case descriptor.FieldDescriptorProto_TYPE_ENUM:
-		return fmt.Sprintf("%s%s", pkg, shortType(*f.TypeName))
-	default:
-		return "Generic"
+		return ""
+	})
+}
+
+// goPackagePath returns the import path of the Go package generated for the file,
+// from its `go_package` option or, as protoc-gen-go does, from the directory of the file.
+func goPackagePath(f *descriptor.FileDescriptorProto) string {
+	gopkg := goPackageOption(f)
+	if i := strings.Index(gopkg, ";"); i >= 0 {
+		gopkg = gopkg[:i]
+	}
+	if strings.Contains(gopkg, "/") {
+		return gopkg
+	}
+	return path.Dir(f.GetName())
+}
+
+// goPackageName returns the name of the Go package generated for the file.
+func goPackageName(f *descriptor.FileDescriptorProto) string {
+	gopkg := goPackageOption(f)
+	if i := strings.Index(gopkg, ";"); i >= 0 {
+		return gopkg[i+1:]
+	}
+	name := path.Base(gopkg)
+	if gopkg == "" {
+		name = f.GetPackage()
+	}
+	if name == "" {
+		name = strings.TrimSuffix(path.Base(f.GetName()), path.Ext(f.GetName()))
 	}
+	return strings.NewReplacer(".", "_", "-", "_").Replace(name)
 }
 
+// goType returns the Go type generated by protoc-gen-go for a field, the messages and enums being prefixed by pkg.
 func goType(pkg string, f *descriptor.FieldDescriptorProto) string {
 	if pkg != "" {
 		pkg = pkg + "."
 	}
-	switch *f.Type {
-	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]float64"
-		}
-		return "float64"
-	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]float32"
-		}
-		return "float32"
-	case descriptor.FieldDescriptorProto_TYPE_INT64:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]int64"
-		}
-		return "int64"
-	case descriptor.FieldDescriptorProto_TYPE_UINT64:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]uint64"
-		}
-		return "uint64"
-	case descriptor.FieldDescriptorProto_TYPE_INT32:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]int32"
-		}
-		return "int32"
-	case descriptor.FieldDescriptorProto_TYPE_UINT32:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]uint32"
-		}
-		return "uint32"
-	case descriptor.FieldDescriptorProto_TYPE_BOOL:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]bool"
-		}
-		return "bool"
-	case descriptor.FieldDescriptorProto_TYPE_STRING:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]string"
-		}
-		return "string"
-	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return fmt.Sprintf("[]*%s%s", pkg, shortType(*f.TypeName))
-		}
-		return fmt.Sprintf("*%s%s", pkg, shortType(*f.TypeName))
-	case descriptor.FieldDescriptorProto_TYPE_BYTES:
-		if *f.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-			return "[]byte"
-		}
-		return "byte"
-	case descriptor.FieldDescriptorProto_TYPE_ENUM:
-		return fmt.Sprintf("*%s%s", pkg, shortType(*f.TypeName))
-	default:
-		return "interface{}"
-	}
+	return goFieldType(f, func(string) string { return pkg })
 }
 
 func jsType(f *descriptor.FieldDescriptorProto) string {
+	if key, value := mapKeyField(f), mapValueField(f); key != nil {
+		return fmt.Sprintf("{ [key: %s]: %s }", jsType(key), jsType(value))
+	}
 	template := "%s"
 	if isFieldRepeated(f) == true {
 		template = "Array<%s>"
@@ -350,8 +337,8 @@ func jsSuffixReservedKeyword(s string) string {
 }
 
 func getPackageTypeName(s string) string {
-	if strings.Compare(s, ".google.protobuf.Timestamp") == 0 {
-		return "timestamp"
+	if file := declaringFile(s); file != nil {
+		return goImportAlias(file.FileDescriptorProto)
 	}
 	if strings.Contains(s, ".") {
 		return strings.Split(s, ".")[1]
@@ -370,85 +357,26 @@ func namespacedFlowType(s string) string {
 	return strings.Join(splitted, "$")
 }
 
+// httpPath returns the path template of the primary HTTP binding of a method, or an empty string.
 func httpPath(m *descriptor.MethodDescriptorProto) string {
-
-	ext, err := proto.GetExtension(m.Options, options.E_Http)
-	if err != nil {
-		return err.Error()
-	}
-	opts, ok := ext.(*options.HttpRule)
-	if !ok {
-		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
-	}
-
-	switch t := opts.Pattern.(type) {
-	default:
-		return ""
-	case *options.HttpRule_Get:
-		return t.Get
-	case *options.HttpRule_Post:
-		return t.Post
-	case *options.HttpRule_Put:
-		return t.Put
-	case *options.HttpRule_Delete:
-		return t.Delete
-	case *options.HttpRule_Patch:
-		return t.Patch
-	case *options.HttpRule_Custom:
-		return t.Custom.Path
+	if binding := primaryHTTPBinding(m); binding != nil {
+		return binding.Path
 	}
+	return ""
 }
 
+// httpVerb returns the HTTP method of the primary HTTP binding of a method, or an empty string.
 func httpVerb(m *descriptor.MethodDescriptorProto) string {
-
-	ext, err := proto.GetExtension(m.Options, options.E_Http)
-	if err != nil {
-		return err.Error()
-	}
-	opts, ok := ext.(*options.HttpRule)
-	if !ok {
-		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
-	}
-
-	switch t := opts.Pattern.(type) {
-	default:
-		return ""
-	case *options.HttpRule_Get:
-		return "GET"
-	case *options.HttpRule_Post:
-		return "POST"
-	case *options.HttpRule_Put:
-		return "PUT"
-	case *options.HttpRule_Delete:
-		return "DELETE"
-	case *options.HttpRule_Patch:
-		return "PATCH"
-	case *options.HttpRule_Custom:
-		return t.Custom.Kind
+	if binding := primaryHTTPBinding(m); binding != nil {
+		return binding.Verb
 	}
+	return ""
 }
 
+// httpBody returns the body of the primary HTTP binding of a method, or an empty string.
 func httpBody(m *descriptor.MethodDescriptorProto) string {
-
-	ext, err := proto.GetExtension(m.Options, options.E_Http)
-	if err != nil {
-		return err.Error()
-	}
-	opts, ok := ext.(*options.HttpRule)
-	if !ok {
-		return fmt.Sprintf("extension is %T; want an HttpRule", ext)
+	if binding := primaryHTTPBinding(m); binding != nil {
+		return binding.Body
 	}
-	return opts.Body
-}
-
-func urlHasVarsFromMessage(path string, d *ggdescriptor.Message) bool {
-	for _, field := range d.Field {
-		if !isFieldMessage(field) {
-			if strings.Contains(path, fmt.Sprintf("{%s}", *field.Name)) {
-				return true
-			}
-		}
-	}
-
-	return false
+	return ""
 }
@@ -0,0 +1,87 @@
+package pgghelpers
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	options "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// shopRequest returns a request of a single file declaring a `ShopService.GetProduct` method bound to path.
+func shopRequest(t *testing.T, path string) (*plugin.CodeGeneratorRequest, *descriptor.MethodDescriptorProto) {
+	method := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("GetProduct"),
+		InputType:  proto.String(".shop.GetProductRequest"),
+		OutputType: proto.String(".shop.Product"),
+		Options:    &descriptor.MethodOptions{},
+	}
+	rule := &options.HttpRule{Pattern: &options.HttpRule_Get{Get: path}}
+	if err := proto.SetExtension(method.Options, options.E_Http, rule); err != nil {
+		t.Fatal(err)
+	}
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("shop/shop.proto"),
+		Package: proto.String("shop"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("github.com/acme/shop;shoppb")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("GetProductRequest"),
+				Field: []*descriptor.FieldDescriptorProto{{
+					Name:   proto.String("id"),
+					Number: proto.Int32(1),
+					Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				}},
+			},
+			{Name: proto.String("Product")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name:   proto.String("ShopService"),
+			Method: []*descriptor.MethodDescriptorProto{method},
+		}},
+	}
+	return &plugin.CodeGeneratorRequest{FileToGenerate: []string{file.GetName()}, ProtoFile: []*descriptor.FileDescriptorProto{file}}, method
+}
+
+func TestGetMessageTypeTemplate(t *testing.T) {
+	tests := []struct {
+		path string
+		text string
+		want string
+	}{
+		{"/v1/products/{id}", `{{urlHasVarsFromMessage (httpPath .) (getMessageType $.File .InputType)}}`, "true"},
+		{"/v1/products", `{{urlHasVarsFromMessage (httpPath .) (getMessageType $.File .InputType)}}`, "false"},
+		{"/v1/products/{id}", `{{with getMessageType $.File .OutputType}}{{.File.GoPkg.Path}} {{.File.GoPkg.Name}} {{.File.GoPkg}}{{end}}`, `github.com/acme/shop shoppb "github.com/acme/shop"`},
+	}
+	for _, test := range tests {
+		req, method := shopRequest(t, test.path)
+		SetModel(NewModel(req))
+		tmpl := template.Must(template.New("").Funcs(ProtoHelpersFuncMap).Parse(`{{with .Method}}` + test.text + `{{end}}`))
+		buffer := new(bytes.Buffer)
+		err := tmpl.Execute(buffer, struct {
+			File   *descriptor.FileDescriptorProto
+			Method *descriptor.MethodDescriptorProto
+		}{req.ProtoFile[0], method})
+		if err != nil {
+			t.Errorf("%s: %v", test.text, err)
+		} else if buffer.String() != test.want {
+			t.Errorf("%s with %s = %q, want %q", test.text, test.path, buffer.String(), test.want)
+		}
+	}
+	SetModel(nil)
+}
+
+func TestHTTPRuleUnparsedPath(t *testing.T) {
+	_, method := shopRequest(t, "/v1/{id")
+	if !hasHttpRule(method) || httpVerb(method) != "GET" || httpPath(method) != "/v1/{id" {
+		t.Errorf("hasHttpRule, httpVerb, httpPath = %v, %q, %q, want true, GET, /v1/{id", hasHttpRule(method), httpVerb(method), httpPath(method))
+	}
+	bindings, err := httpRules(method)
+	if err != nil || len(bindings) != 1 || bindings[0].Template != nil {
+		t.Errorf("httpRules = %v, %v, want a binding without template", bindings, err)
+	}
+}
@@ -0,0 +1,140 @@
+package pgghelpers
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	options "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// HTTPBinding is an HTTP binding of a method, declared by its `google.api.http` option.
+type HTTPBinding struct {
+	// Verb is the HTTP method, or the kind of a custom pattern
+	Verb string
+	// Path is the path template, i.e: `/v1/{name=shelves/*}/books`
+	Path string
+	// Template and Variables are nil if the path cannot be parsed, the error being reported by the helpers
+	// parsing the path, i.e: `httpPathTemplate` or `expressPath`
+	Template  *PathTemplate
+	Variables []*PathVariable
+	// Body is the request field mapped to the HTTP body, `*` for the fields not bound by the path
+	Body string
+	// ResponseBody is the response field mapped to the HTTP body, empty for the whole response
+	ResponseBody string
+	// Additional is set for the `additional_bindings` of the rule
+	Additional bool
+}
+
+// httpRules returns the HTTP bindings of a method, the primary binding first and then its `additional_bindings`.
+// The vendored HttpRule lacks `response_body`, so the rule is decoded from the encoded options.
+func httpRules(m *descriptor.MethodDescriptorProto) ([]*HTTPBinding, error) {
+	if m.GetOptions() == nil {
+		return nil, nil
+	}
+	raw, err := rawOptions(m.GetOptions())
+	if err != nil {
+		return nil, fmt.Errorf("httpRules: %v", err)
+	}
+	fields, err := readRawFields(raw)
+	if err != nil {
+		return nil, fmt.Errorf("httpRules: %v", err)
+	}
+	// the occurrences of the extension are merged, as for any message
+	rule := []byte{}
+	found := false
+	for _, field := range fields {
+		if field.Number == options.E_Http.Field && field.WireType == proto.WireBytes {
+			rule, found = append(rule, field.Bytes...), true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	bindings, err := decodeHTTPRule(rule)
+	if err != nil {
+		return nil, fmt.Errorf("httpRules: %s: %v", m.GetName(), err)
+	}
+	return bindings, nil
+}
+
+// hasHttpRule reports whether a method has a `google.api.http` option.
+func hasHttpRule(m *descriptor.MethodDescriptorProto) bool {
+	bindings, err := httpRules(m)
+	return err == nil && len(bindings) > 0
+}
+
+// primaryHTTPBinding returns the primary binding of a method, or nil.
+func primaryHTTPBinding(m *descriptor.MethodDescriptorProto) *HTTPBinding {
+	bindings, err := httpRules(m)
+	if err != nil || len(bindings) == 0 || bindings[0].Additional {
+		return nil
+	}
+	return bindings[0]
+}
+
+// decodeHTTPRule decodes an encoded HttpRule, the rules without pattern being skipped.
+func decodeHTTPRule(raw []byte) ([]*HTTPBinding, error) {
+	fields, err := readRawFields(raw)
+	if err != nil {
+		return nil, err
+	}
+	binding := &HTTPBinding{}
+	additional := []*HTTPBinding{}
+	for _, field := range fields {
+		switch field.Number {
+		case 2:
+			binding.Verb, binding.Path = "GET", string(field.Bytes)
+		case 3:
+			binding.Verb, binding.Path = "PUT", string(field.Bytes)
+		case 4:
+			binding.Verb, binding.Path = "POST", string(field.Bytes)
+		case 5:
+			binding.Verb, binding.Path = "DELETE", string(field.Bytes)
+		case 6:
+			binding.Verb, binding.Path = "PATCH", string(field.Bytes)
+		case 8:
+			custom, err := readRawFields(field.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("custom: %v", err)
+			}
+			binding.Verb, binding.Path = "", ""
+			for _, f := range custom {
+				switch f.Number {
+				case 1:
+					binding.Verb = string(f.Bytes)
+				case 2:
+					binding.Path = string(f.Bytes)
+				}
+			}
+		case 7:
+			binding.Body = string(field.Bytes)
+		case 12:
+			binding.ResponseBody = string(field.Bytes)
+		case 11:
+			// nested bindings cannot have additional bindings themselves
+			nested, err := decodeHTTPRule(field.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("additional_bindings: %v", err)
+			}
+			if len(nested) > 0 && !nested[0].Additional {
+				nested[0].Additional = true
+				additional = append(additional, nested[0])
+			}
+		}
+	}
+
+	bindings := []*HTTPBinding{}
+	if binding.Path != "" {
+		bindings = append(bindings, binding)
+	}
+	bindings = append(bindings, additional...)
+	for _, b := range bindings {
+		if b.Template == nil {
+			if tpl, err := parsePathTemplate(b.Path); err == nil {
+				b.Template, b.Variables = tpl, tpl.Variables()
+			}
+		}
+	}
+	return bindings, nil
+}
@@ -0,0 +1,66 @@
+package pgghelpers
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// MethodKind tells the unary methods from the streaming ones.
+type MethodKind string
+
+// The kinds of methods, as returned by `methodKind`.
+const (
+	MethodUnary        MethodKind = "unary"
+	MethodServerStream MethodKind = "server-stream"
+	MethodClientStream MethodKind = "client-stream"
+	MethodBidiStream   MethodKind = "bidi"
+)
+
+var methodKinds = []MethodKind{MethodUnary, MethodServerStream, MethodClientStream, MethodBidiStream}
+
+// methodKind returns the kind of a method, i.e: `{{if eq (methodKind .) "server-stream"}}`.
+func methodKind(m *descriptor.MethodDescriptorProto) MethodKind {
+	switch {
+	case m.GetClientStreaming() && m.GetServerStreaming():
+		return MethodBidiStream
+	case m.GetClientStreaming():
+		return MethodClientStream
+	case m.GetServerStreaming():
+		return MethodServerStream
+	}
+	return MethodUnary
+}
+
+// methodsByKind returns the methods of a service of the given kinds, i.e: `{{range methodsByKind .Service "unary" "bidi"}}`.
+func methodsByKind(svc *descriptor.ServiceDescriptorProto, kinds ...string) ([]*descriptor.MethodDescriptorProto, error) {
+	wanted := make(map[MethodKind]bool)
+	for _, kind := range kinds {
+		found := false
+		for _, known := range methodKinds {
+			found = found || MethodKind(kind) == known
+		}
+		if !found {
+			return nil, fmt.Errorf("methodsByKind: unknown kind %q", kind)
+		}
+		wanted[MethodKind(kind)] = true
+	}
+	methods := []*descriptor.MethodDescriptorProto{}
+	for _, m := range svc.GetMethod() {
+		if wanted[methodKind(m)] {
+			methods = append(methods, m)
+		}
+	}
+	return methods, nil
+}
+
+func unaryMethods(svc *descriptor.ServiceDescriptorProto) []*descriptor.MethodDescriptorProto {
+	methods, _ := methodsByKind(svc, string(MethodUnary))
+	return methods
+}
+
+// streamingMethods returns the methods of a service streaming their requests, their responses or both.
+func streamingMethods(svc *descriptor.ServiceDescriptorProto) []*descriptor.MethodDescriptorProto {
+	methods, _ := methodsByKind(svc, string(MethodServerStream), string(MethodClientStream), string(MethodBidiStream))
+	return methods
+}
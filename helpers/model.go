@@ -0,0 +1,330 @@
+package pgghelpers
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+var (
+	model *Model // some helpers need access to the model
+)
+
+func SetModel(m *Model) {
+	model = m
+}
+
+// Model is a resolved view of all the descriptors of a CodeGeneratorRequest.
+// Fully qualified names are written without leading dot, i.e: `package.Outer.Inner`.
+// Links to parents and resolved types are not serialized to JSON to avoid cycles.
+type Model struct {
+	Files []*File
+
+	files    map[string]*File
+	messages map[string]*Message
+	enums    map[string]*Enum
+	services map[string]*Service
+	fields   map[*descriptor.FieldDescriptorProto]*Field
+	// extensions are the extensions declared in the files, options the encoded options of the elements
+	extensions map[string]*descriptor.FieldDescriptorProto
+	options    map[proto.Message][]byte
+	// goAliases are the import aliases of the Go packages of the files
+	goAliases map[string]string
+}
+
+type File struct {
+	*descriptor.FileDescriptorProto
+	// Messages and Enums are the top-level messages and enums of the file
+	Messages []*Message
+	Enums    []*Enum
+	// AllMessages and AllEnums include the nested messages and enums, the synthetic entries of map fields excluded
+	AllMessages  []*Message `json:"-"`
+	AllEnums     []*Enum    `json:"-"`
+	Services     []*Service
+	Dependencies []*File `json:"-"`
+	// Comments are the comments of the package statement
+	Comments Comments
+}
+
+type Message struct {
+	*descriptor.DescriptorProto
+	FullName string
+	File     *File    `json:"-"`
+	Parent   *Message `json:"-"`
+	Fields   []*Field
+	// Messages and Enums are the nested messages and enums, the synthetic entries of map fields excluded
+	Messages []*Message
+	Enums    []*Enum
+	// MapEntries are the synthetic `<Field>Entry` messages of the map fields
+	MapEntries []*Message `json:"-"`
+	// Oneofs are the oneofs of the message, the synthetic oneofs of the proto3 optional fields excluded
+	Oneofs   []*Oneof
+	Comments Comments
+}
+
+type Field struct {
+	*descriptor.FieldDescriptorProto
+	FullName string
+	// Message is the message declaring the field
+	Message *Message `json:"-"`
+	// MessageType and EnumType are the resolved types of message and enum fields
+	MessageType *Message `json:"-"`
+	EnumType    *Enum    `json:"-"`
+	// MapKey and MapValue are the key and value fields of map fields
+	MapKey   *Field `json:"-"`
+	MapValue *Field `json:"-"`
+	// Oneof is the oneof of the field, nil for the proto3 optional fields
+	Oneof          *Oneof `json:"-"`
+	Proto3Optional bool
+	Comments       Comments
+}
+
+type Enum struct {
+	*descriptor.EnumDescriptorProto
+	FullName string
+	File     *File    `json:"-"`
+	Parent   *Message `json:"-"`
+	Values   []*EnumValue
+	Comments Comments
+}
+
+type EnumValue struct {
+	*descriptor.EnumValueDescriptorProto
+	FullName string
+	Enum     *Enum `json:"-"`
+	Comments Comments
+}
+
+type Service struct {
+	*descriptor.ServiceDescriptorProto
+	FullName string
+	File     *File `json:"-"`
+	Methods  []*Method
+	Comments Comments
+}
+
+type Method struct {
+	*descriptor.MethodDescriptorProto
+	FullName string
+	Service  *Service `json:"-"`
+	// Input and Output are the resolved request and response messages
+	Input    *Message `json:"-"`
+	Output   *Message `json:"-"`
+	Kind     MethodKind
+	Comments Comments
+}
+
+// NewModel builds the model of all the files of the request, imported files included.
+func NewModel(req *plugin.CodeGeneratorRequest) *Model {
+	m := &Model{
+		files:      make(map[string]*File),
+		messages:   make(map[string]*Message),
+		enums:      make(map[string]*Enum),
+		services:   make(map[string]*Service),
+		fields:     make(map[*descriptor.FieldDescriptorProto]*Field),
+		extensions: make(map[string]*descriptor.FieldDescriptorProto),
+		options:    make(map[proto.Message][]byte),
+	}
+	for _, fd := range req.GetProtoFile() {
+		m.loadFile(fd)
+	}
+	for _, file := range m.Files {
+		for _, dep := range file.GetDependency() {
+			if f := m.files[dep]; f != nil {
+				file.Dependencies = append(file.Dependencies, f)
+			}
+		}
+		for _, svc := range file.Services {
+			for _, method := range svc.Methods {
+				method.Input = m.messages[trimDot(method.GetInputType())]
+				method.Output = m.messages[trimDot(method.GetOutputType())]
+			}
+		}
+	}
+	// map entries are not listed in the files, resolve the fields of all the messages
+	for _, msg := range m.messages {
+		for _, field := range msg.Fields {
+			field.MessageType = m.messages[trimDot(field.GetTypeName())]
+			field.EnumType = m.enums[trimDot(field.GetTypeName())]
+			if entry := field.MessageType; entry != nil && entry.GetOptions().GetMapEntry() && len(entry.Fields) == 2 {
+				field.MapKey, field.MapValue = entry.Fields[0], entry.Fields[1]
+			}
+		}
+	}
+	m.goAliases = goAliases(m.Files, req.GetFileToGenerate())
+	return m
+}
+
+func (m *Model) loadFile(fd *descriptor.FileDescriptorProto) {
+	comments := newSourceComments(fd)
+	file := &File{FileDescriptorProto: fd, Comments: comments.get([]int32{filePackagePath})}
+	m.Files = append(m.Files, file)
+	m.files[fd.GetName()] = file
+	m.loadOptions(fd.GetOptions())
+
+	prefix := fd.GetPackage()
+	m.loadExtensions(prefix, fd.GetExtension())
+	for i, ed := range fd.GetEnumType() {
+		file.Enums = append(file.Enums, m.loadEnum(file, nil, prefix, ed, comments, []int32{fileEnumPath, int32(i)}))
+	}
+	for i, md := range fd.GetMessageType() {
+		file.Messages = append(file.Messages, m.loadMessage(file, nil, prefix, md, comments, []int32{fileMessagePath, int32(i)}))
+	}
+	for i, sd := range fd.GetService() {
+		path := []int32{fileServicePath, int32(i)}
+		svc := &Service{ServiceDescriptorProto: sd, FullName: qualify(prefix, sd.GetName()), File: file, Comments: comments.get(path)}
+		m.loadOptions(sd.GetOptions())
+		for j, md := range sd.GetMethod() {
+			m.loadOptions(md.GetOptions())
+			svc.Methods = append(svc.Methods, &Method{
+				MethodDescriptorProto: md,
+				FullName:              qualify(svc.FullName, md.GetName()),
+				Service:               svc,
+				Kind:                  methodKind(md),
+				Comments:              comments.get(appendPath(path, serviceMethodPath, int32(j))),
+			})
+		}
+		file.Services = append(file.Services, svc)
+		m.services[svc.FullName] = svc
+	}
+}
+
+func (m *Model) loadMessage(file *File, parent *Message, prefix string, md *descriptor.DescriptorProto, comments sourceComments, path []int32) *Message {
+	msg := &Message{DescriptorProto: md, FullName: qualify(prefix, md.GetName()), File: file, Parent: parent, Comments: comments.get(path)}
+	if !md.GetOptions().GetMapEntry() {
+		file.AllMessages = append(file.AllMessages, msg)
+	}
+	m.messages[msg.FullName] = msg
+	m.loadOptions(md.GetOptions())
+	m.loadExtensions(msg.FullName, md.GetExtension())
+
+	for i, fd := range md.GetField() {
+		m.loadOptions(fd.GetOptions())
+		field := &Field{
+			FieldDescriptorProto: fd,
+			FullName:             qualify(msg.FullName, fd.GetName()),
+			Message:              msg,
+			Comments:             comments.get(appendPath(path, messageFieldPath, int32(i))),
+		}
+		msg.Fields = append(msg.Fields, field)
+		m.fields[fd] = field
+	}
+	m.loadOneofs(msg, comments, path)
+	for i, ed := range md.GetEnumType() {
+		msg.Enums = append(msg.Enums, m.loadEnum(file, msg, msg.FullName, ed, comments, appendPath(path, messageEnumPath, int32(i))))
+	}
+	for i, nested := range md.GetNestedType() {
+		nestedMsg := m.loadMessage(file, msg, msg.FullName, nested, comments, appendPath(path, messageNestedPath, int32(i)))
+		if nested.GetOptions().GetMapEntry() {
+			msg.MapEntries = append(msg.MapEntries, nestedMsg)
+		} else {
+			msg.Messages = append(msg.Messages, nestedMsg)
+		}
+	}
+	return msg
+}
+
+func (m *Model) loadEnum(file *File, parent *Message, prefix string, ed *descriptor.EnumDescriptorProto, comments sourceComments, path []int32) *Enum {
+	enum := &Enum{EnumDescriptorProto: ed, FullName: qualify(prefix, ed.GetName()), File: file, Parent: parent, Comments: comments.get(path)}
+	// enum values are scoped like their enum, not inside it
+	m.loadOptions(ed.GetOptions())
+	for i, vd := range ed.GetValue() {
+		m.loadOptions(vd.GetOptions())
+		enum.Values = append(enum.Values, &EnumValue{
+			EnumValueDescriptorProto: vd,
+			FullName:                 qualify(prefix, vd.GetName()),
+			Enum:                     enum,
+			Comments:                 comments.get(appendPath(path, enumValuePath, int32(i))),
+		})
+	}
+	file.AllEnums = append(file.AllEnums, enum)
+	m.enums[enum.FullName] = enum
+	return enum
+}
+
+// LookupFile returns the file with the given name, or nil.
+func (m *Model) LookupFile(name string) *File {
+	return m.files[name]
+}
+
+// LookupMessage returns the message with the given fully qualified name, with or without leading dot, or nil.
+func (m *Model) LookupMessage(name string) *Message {
+	return m.messages[trimDot(name)]
+}
+
+// LookupEnum returns the enum with the given fully qualified name, with or without leading dot, or nil.
+func (m *Model) LookupEnum(name string) *Enum {
+	return m.enums[trimDot(name)]
+}
+
+// resolveMessage resolves a message type name as protoc does from a scope, i.e: a package, fully qualified
+// names starting with a dot and the other names being looked up in the scope and then in its parents.
+func (m *Model) resolveMessage(scope, name string) *Message {
+	var msg *Message
+	resolveTypeName(scope, name, func(name string) bool {
+		msg = m.LookupMessage(name)
+		return msg != nil
+	})
+	return msg
+}
+
+// resolveEnum resolves an enum type name from a scope, see resolveMessage.
+func (m *Model) resolveEnum(scope, name string) *Enum {
+	var enum *Enum
+	resolveTypeName(scope, name, func(name string) bool {
+		enum = m.LookupEnum(name)
+		return enum != nil
+	})
+	return enum
+}
+
+// resolveTypeName calls lookup with the candidate fully qualified names of a type name, until it returns true.
+func resolveTypeName(scope, name string, lookup func(name string) bool) {
+	if strings.HasPrefix(name, ".") {
+		lookup(name)
+		return
+	}
+	for !lookup(qualify(scope, name)) && scope != "" {
+		if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// LookupService returns the service with the given fully qualified name, with or without leading dot, or nil.
+func (m *Model) LookupService(name string) *Service {
+	return m.services[trimDot(name)]
+}
+
+// LookupMethod returns the method of the service with the given name, or nil.
+func (s *Service) LookupMethod(name string) *Method {
+	for _, method := range s.Methods {
+		if method.GetName() == name {
+			return method
+		}
+	}
+	return nil
+}
+
+// lookupField returns the field of the model wrapping the given descriptor, or nil.
+func (m *Model) lookupField(fd *descriptor.FieldDescriptorProto) *Field {
+	if m == nil {
+		return nil
+	}
+	return m.fields[fd]
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func trimDot(name string) string {
+	return strings.TrimPrefix(name, ".")
+}
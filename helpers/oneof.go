@@ -0,0 +1,72 @@
+package pgghelpers
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// fieldProto3OptionalNumber is the number of the `proto3_optional` field of FieldDescriptorProto,
+// unknown to the vendored descriptors.
+const fieldProto3OptionalNumber = 17
+
+// Oneof is a oneof of a message with its member fields.
+type Oneof struct {
+	*descriptor.OneofDescriptorProto
+	FullName string
+	// Message is the message declaring the oneof
+	Message *Message `json:"-"`
+	Fields  []*Field
+	// Synthetic oneofs are generated by protoc for the proto3 `optional` fields
+	Synthetic bool
+	Comments  Comments
+}
+
+// loadOneofs groups the fields of a message by oneof, the fields being already loaded.
+func (m *Model) loadOneofs(msg *Message, comments sourceComments, path []int32) {
+	oneofs := make([]*Oneof, len(msg.GetOneofDecl()))
+	for i, od := range msg.GetOneofDecl() {
+		m.loadOptions(od.GetOptions())
+		oneofs[i] = &Oneof{
+			OneofDescriptorProto: od,
+			FullName:             qualify(msg.FullName, od.GetName()),
+			Message:              msg,
+			Comments:             comments.get(appendPath(path, messageOneofPath, int32(i))),
+		}
+	}
+	for _, field := range msg.Fields {
+		field.Proto3Optional = isFieldProto3Optional(field.FieldDescriptorProto)
+		if field.OneofIndex == nil || int(field.GetOneofIndex()) >= len(oneofs) {
+			continue
+		}
+		oneof := oneofs[field.GetOneofIndex()]
+		oneof.Fields = append(oneof.Fields, field)
+		// a synthetic oneof has a single proto3 optional field
+		oneof.Synthetic = field.Proto3Optional
+		if !oneof.Synthetic {
+			field.Oneof = oneof
+		}
+	}
+	for _, oneof := range oneofs {
+		if !oneof.Synthetic {
+			msg.Oneofs = append(msg.Oneofs, oneof)
+		}
+	}
+}
+
+// isFieldOneof reports whether a field is a member of a oneof, the synthetic oneofs of the proto3 `optional` fields excluded.
+func isFieldOneof(f *descriptor.FieldDescriptorProto) bool {
+	return f.OneofIndex != nil && !isFieldProto3Optional(f)
+}
+
+// isFieldProto3Optional reports whether a field is declared with the proto3 `optional` keyword.
+func isFieldProto3Optional(f *descriptor.FieldDescriptorProto) bool {
+	value, found := rawVarint(f.XXX_unrecognized, fieldProto3OptionalNumber)
+	return found && value != 0
+}
+
+// getOneof returns the oneof of a field, or nil if the field is not a member of a oneof.
+func getOneof(f *descriptor.FieldDescriptorProto) *Oneof {
+	if field := model.lookupField(f); field != nil {
+		return field.Oneof
+	}
+	return nil
+}
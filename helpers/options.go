@@ -0,0 +1,240 @@
+package pgghelpers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// loadExtensions indexes the extensions declared in a file or in a message by fully qualified name.
+func (m *Model) loadExtensions(prefix string, extensions []*descriptor.FieldDescriptorProto) {
+	for _, ext := range extensions {
+		m.extensions[qualify(prefix, ext.GetName())] = ext
+	}
+}
+
+// loadOptions keeps the encoded options of an element, custom options included.
+// The options are encoded once while loading the model, as decoding an extension later modifies them.
+func (m *Model) loadOptions(opts proto.Message) {
+	if reflect.ValueOf(opts).IsNil() {
+		return
+	}
+	if raw, err := proto.Marshal(opts); err == nil {
+		m.options[opts] = raw
+	}
+}
+
+// rawOptions returns the encoded options of an element, as loaded in the model if possible.
+func rawOptions(opts proto.Message) ([]byte, error) {
+	if model != nil {
+		if raw, found := model.options[opts]; found {
+			return raw, nil
+		}
+	}
+	return proto.Marshal(opts)
+}
+
+// LookupExtension returns the extension with the given fully qualified name, with or without leading dot, or nil.
+func (m *Model) LookupExtension(name string) *descriptor.FieldDescriptorProto {
+	return m.extensions[trimDot(name)]
+}
+
+// getOption decodes the value of a custom option, i.e: `getOption .Method.Options "company.auth"`,
+// using the extension declared in the files of the request.
+// Messages are decoded as maps of their field names, repeated fields as lists, and enums as the names of their values.
+// It returns nil if the option is not set.
+func getOption(opts proto.Message, name string) (interface{}, error) {
+	if model == nil {
+		return nil, fmt.Errorf("getOption: no model")
+	}
+	ext := model.LookupExtension(name)
+	if ext == nil {
+		return nil, fmt.Errorf("getOption: unknown extension %q", name)
+	}
+	if extendee := "." + proto.MessageName(opts); ext.GetExtendee() != extendee {
+		return nil, fmt.Errorf("getOption: extension %q extends %s, not %s", name, ext.GetExtendee(), extendee)
+	}
+	if reflect.ValueOf(opts).IsNil() {
+		return nil, nil
+	}
+	raw, err := rawOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("getOption: %v", err)
+	}
+	fields, err := readRawFields(raw)
+	if err != nil {
+		return nil, fmt.Errorf("getOption: %v", err)
+	}
+	return decodeField(ext, fields)
+}
+
+// getExtension is like getOption, taking the descriptor of a file, message, field, enum, enum value, service or method.
+func getExtension(desc interface{}, name string) (interface{}, error) {
+	var opts proto.Message
+	switch d := desc.(type) {
+	case *descriptor.FileDescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.DescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.FieldDescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.OneofDescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.EnumDescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.EnumValueDescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.ServiceDescriptorProto:
+		opts = d.GetOptions()
+	case *descriptor.MethodDescriptorProto:
+		opts = d.GetOptions()
+	default:
+		return nil, fmt.Errorf("getExtension: unsupported descriptor %T", desc)
+	}
+	return getOption(opts, name)
+}
+
+// decodeField decodes the occurrences of a field in the fields of an encoded message.
+func decodeField(f *descriptor.FieldDescriptorProto, fields []rawField) (interface{}, error) {
+	values := []interface{}{}
+	for _, raw := range fields {
+		if raw.Number != f.GetNumber() {
+			continue
+		}
+		decoded, err := decodeValues(f, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.GetName(), err)
+		}
+		values = append(values, decoded...)
+	}
+
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		m := make(map[string]interface{})
+		for _, value := range values {
+			if kv, ok := value.(map[string]interface{}); ok {
+				m[fmt.Sprint(kv[entry.Fields[0].GetName()])] = kv[entry.Fields[1].GetName()]
+			}
+		}
+		return m, nil
+	}
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return values, nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	// the occurrences of a singular message are merged, the last occurrence of a scalar wins
+	if merged, ok := values[0].(map[string]interface{}); ok {
+		for _, value := range values[1:] {
+			for k, v := range value.(map[string]interface{}) {
+				merged[k] = v
+			}
+		}
+		return merged, nil
+	}
+	return values[len(values)-1], nil
+}
+
+// decodeValues decodes an occurrence of a field, packed repeated fields having several values.
+func decodeValues(f *descriptor.FieldDescriptorProto, raw rawField) ([]interface{}, error) {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return []interface{}{string(raw.Bytes)}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return []interface{}{raw.Bytes}, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// the Bytes of a group are its encoded fields, as for a message
+		msg := model.LookupMessage(f.GetTypeName())
+		if msg == nil {
+			return nil, fmt.Errorf("unknown message %s", f.GetTypeName())
+		}
+		fields, err := readRawFields(raw.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		value := make(map[string]interface{})
+		for _, field := range msg.Fields {
+			decoded, err := decodeField(field.FieldDescriptorProto, fields)
+			if err != nil {
+				return nil, err
+			}
+			if decoded != nil {
+				value[field.GetName()] = decoded
+			}
+		}
+		return []interface{}{value}, nil
+	}
+
+	if raw.WireType != proto.WireBytes {
+		value, err := decodeScalar(f, raw.Varint)
+		return []interface{}{value}, err
+	}
+	// packed repeated scalars
+	values := []interface{}{}
+	packed := raw.Bytes
+	for len(packed) > 0 {
+		var v uint64
+		switch f.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+			if len(packed) < 8 {
+				return nil, fmt.Errorf("truncated packed field")
+			}
+			v, packed = binary.LittleEndian.Uint64(packed), packed[8:]
+		case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+			if len(packed) < 4 {
+				return nil, fmt.Errorf("truncated packed field")
+			}
+			v, packed = uint64(binary.LittleEndian.Uint32(packed)), packed[4:]
+		default:
+			var n int
+			if v, n = proto.DecodeVarint(packed); n == 0 {
+				return nil, fmt.Errorf("invalid packed varint")
+			}
+			packed = packed[n:]
+		}
+		value, err := decodeScalar(f, v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func decodeScalar(f *descriptor.FieldDescriptorProto, v uint64) (interface{}, error) {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return math.Float64frombits(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return math.Float32frombits(uint32(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return v, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return int64(v>>1) ^ -int64(v&1), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return int32(v>>1) ^ -int32(v&1), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v != 0, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum := model.LookupEnum(f.GetTypeName()); enum != nil {
+			for _, value := range enum.Values {
+				if int64(value.GetNumber()) == int64(int32(v)) {
+					return value.GetName(), nil
+				}
+			}
+		}
+		return strconv.Itoa(int(int32(v))), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", f.GetType())
+}
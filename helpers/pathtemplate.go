@@ -0,0 +1,371 @@
+package pgghelpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// PathTemplate is a parsed `google.api.http` path template:
+//
+//	Template = "/" [ Segments [ "/" ] ] [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	Verb     = ":" LITERAL ;
+type PathTemplate struct {
+	Segments []*PathSegment
+	// Verb is the custom verb of the template, i.e: `cancel` for `/v1/{name=operations/**}:cancel`
+	Verb string
+	// TrailingSlash is set for the paths ending by a slash, i.e: `/v1/users/`, accepted as grpc-gateway does
+	TrailingSlash bool
+}
+
+// PathSegment is a segment of a path template: a literal, a `*` or `**` wildcard, or a variable.
+type PathSegment struct {
+	Literal  string
+	Wildcard string
+	Variable *PathVariable
+}
+
+// PathVariable is a variable of a path template, i.e: `{user.id}` or `{name=shelves/*}`.
+type PathVariable struct {
+	// FieldPath is the path of the request field bound to the variable, i.e: `user.id`
+	FieldPath string
+	Fields    []string
+	// Pattern is the segments matched by the variable, `*` when omitted
+	Pattern  string
+	Segments []*PathSegment
+}
+
+var pathIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parsePathTemplate parses a path template, see PathTemplate.
+func parsePathTemplate(path string) (*PathTemplate, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q: must start with '/'", path)
+	}
+	tpl := &PathTemplate{}
+	body := path
+	// the verb follows the last segment, which cannot be a variable
+	if i := strings.LastIndexByte(path, ':'); i > strings.LastIndexByte(path, '/') && i > strings.LastIndexByte(path, '}') {
+		body, tpl.Verb = path[:i], path[i+1:]
+		if tpl.Verb == "" {
+			return nil, fmt.Errorf("path %q: empty verb", path)
+		}
+	}
+	if body == "/" {
+		tpl.Segments = []*PathSegment{}
+		return tpl, nil
+	}
+	if strings.HasSuffix(body, "/") {
+		body, tpl.TrailingSlash = body[:len(body)-1], true
+	}
+	p := &pathParser{input: body, pos: 1}
+	var err error
+	if tpl.Segments, err = p.segments(false); err != nil {
+		return nil, fmt.Errorf("path %q: %v", path, err)
+	}
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("path %q: unexpected %q at offset %d", path, p.input[p.pos], p.pos)
+	}
+	flat := []*PathSegment{}
+	for _, segment := range tpl.Segments {
+		if segment.Variable != nil {
+			flat = append(flat, segment.Variable.Segments...)
+		} else {
+			flat = append(flat, segment)
+		}
+	}
+	for i, segment := range flat {
+		if segment.Wildcard == "**" && i != len(flat)-1 {
+			return nil, fmt.Errorf("path %q: '**' must be the last segment", path)
+		}
+	}
+	return tpl, nil
+}
+
+type pathParser struct {
+	input string
+	pos   int
+}
+
+func (p *pathParser) segments(inVariable bool) ([]*PathSegment, error) {
+	segments := []*PathSegment{}
+	for {
+		segment, err := p.segment(inVariable)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+		if p.pos >= len(p.input) || p.input[p.pos] != '/' {
+			return segments, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *pathParser) segment(inVariable bool) (*PathSegment, error) {
+	rest := p.input[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "**"):
+		p.pos += 2
+		return &PathSegment{Wildcard: "**"}, nil
+	case strings.HasPrefix(rest, "*"):
+		p.pos++
+		return &PathSegment{Wildcard: "*"}, nil
+	case strings.HasPrefix(rest, "{"):
+		if inVariable {
+			return nil, fmt.Errorf("nested variable at offset %d", p.pos)
+		}
+		variable, err := p.variable()
+		if err != nil {
+			return nil, err
+		}
+		return &PathSegment{Variable: variable}, nil
+	}
+	end := strings.IndexAny(rest, "/{}*")
+	if end < 0 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return nil, fmt.Errorf("empty segment at offset %d", p.pos)
+	}
+	p.pos += end
+	return &PathSegment{Literal: rest[:end]}, nil
+}
+
+func (p *pathParser) variable() (*PathVariable, error) {
+	start := p.pos
+	p.pos++ // {
+	end := strings.IndexAny(p.input[p.pos:], "=}")
+	if end < 0 {
+		return nil, fmt.Errorf("unclosed variable at offset %d", start)
+	}
+	variable := &PathVariable{FieldPath: p.input[p.pos : p.pos+end]}
+	variable.Fields = strings.Split(variable.FieldPath, ".")
+	for _, name := range variable.Fields {
+		if !pathIdentRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid field path %q at offset %d", variable.FieldPath, start)
+		}
+	}
+	p.pos += end
+	if p.input[p.pos] == '=' {
+		p.pos++
+		patternStart := p.pos
+		var err error
+		if variable.Segments, err = p.segments(true); err != nil {
+			return nil, err
+		}
+		variable.Pattern = p.input[patternStart:p.pos]
+	} else {
+		variable.Pattern, variable.Segments = "*", []*PathSegment{{Wildcard: "*"}}
+	}
+	if p.pos >= len(p.input) || p.input[p.pos] != '}' {
+		return nil, fmt.Errorf("unclosed variable at offset %d", start)
+	}
+	p.pos++
+	return variable, nil
+}
+
+// Variables returns the variables of the template, in order.
+func (t *PathTemplate) Variables() []*PathVariable {
+	variables := []*PathVariable{}
+	for _, segment := range t.Segments {
+		if segment.Variable != nil {
+			variables = append(variables, segment.Variable)
+		}
+	}
+	return variables
+}
+
+// render joins the segments of the template, the verb being prefixed by verbSep.
+func (t *PathTemplate) render(segment func(i int, s *PathSegment) string, verbSep string) string {
+	parts := make([]string, len(t.Segments))
+	for i, s := range t.Segments {
+		parts[i] = segment(i, s)
+	}
+	path := "/" + strings.Join(parts, "/")
+	if t.TrailingSlash {
+		path += "/"
+	}
+	if t.Verb != "" {
+		path += verbSep + t.Verb
+	}
+	return path
+}
+
+// String returns the template as written in the `.proto` file.
+func (t *PathTemplate) String() string {
+	return t.render(func(_ int, s *PathSegment) string { return s.String() }, ":")
+}
+
+// Express returns the template as an Express route, i.e: `/v1/users/:user_id`.
+// The verb colon is escaped by a backslash, i.e: `/v1/:name\:cancel`, to be escaped again in a JavaScript string literal.
+func (t *PathTemplate) Express() string {
+	return t.render(func(_ int, s *PathSegment) string {
+		switch {
+		case s.Variable != nil:
+			name := ":" + strings.Replace(s.Variable.FieldPath, ".", "_", -1)
+			if s.Variable.Pattern == "*" {
+				return name
+			}
+			return name + "(" + segmentsRegexp(s.Variable.Segments) + ")"
+		case s.Wildcard != "":
+			return "*"
+		}
+		return s.Literal
+	}, `\:`)
+}
+
+// Mux returns the template as a gorilla/mux route, i.e: `/v1/{name:shelves/[^/]+}`.
+// The wildcards outside of a variable are named after their position, i.e: `{_2:[^/]+}`.
+func (t *PathTemplate) Mux() string {
+	return t.render(func(i int, s *PathSegment) string {
+		switch {
+		case s.Variable != nil:
+			if s.Variable.Pattern == "*" {
+				return "{" + s.Variable.FieldPath + "}"
+			}
+			return "{" + s.Variable.FieldPath + ":" + segmentsRegexp(s.Variable.Segments) + "}"
+		case s.Wildcard != "":
+			return "{_" + strconv.Itoa(i) + ":" + segmentsRegexp([]*PathSegment{s}) + "}"
+		}
+		return s.Literal
+	}, ":")
+}
+
+// OpenAPI returns the template as an OpenAPI path, the patterns of the variables being dropped, i.e: `/v1/{name}`.
+func (t *PathTemplate) OpenAPI() string {
+	return t.render(func(_ int, s *PathSegment) string {
+		if s.Variable != nil {
+			return "{" + s.Variable.FieldPath + "}"
+		}
+		return s.String()
+	}, ":")
+}
+
+// Printf returns the template as a format string with a `%s` verb per variable, i.e: `/v1/users/%s`.
+func (t *PathTemplate) Printf() string {
+	return t.render(func(_ int, s *PathSegment) string {
+		if s.Variable != nil {
+			return "%s"
+		}
+		return strings.Replace(s.String(), "%", "%%", -1)
+	}, ":")
+}
+
+func (s *PathSegment) String() string {
+	switch {
+	case s.Variable != nil:
+		if s.Variable.Pattern == "*" {
+			return "{" + s.Variable.FieldPath + "}"
+		}
+		return "{" + s.Variable.FieldPath + "=" + s.Variable.Pattern + "}"
+	case s.Wildcard != "":
+		return s.Wildcard
+	}
+	return s.Literal
+}
+
+func segmentsRegexp(segments []*PathSegment) string {
+	parts := make([]string, len(segments))
+	for i, s := range segments {
+		switch s.Wildcard {
+		case "*":
+			parts[i] = "[^/]+"
+		case "**":
+			parts[i] = ".+"
+		default:
+			parts[i] = regexp.QuoteMeta(s.Literal)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// renderPath parses a path template and renders it with the given method of PathTemplate.
+func renderPath(render func(*PathTemplate) string) func(path string) (string, error) {
+	return func(path string) (string, error) {
+		tpl, err := parsePathTemplate(path)
+		if err != nil {
+			return "", err
+		}
+		return render(tpl), nil
+	}
+}
+
+var (
+	expressPath = renderPath((*PathTemplate).Express)
+	muxPath     = renderPath((*PathTemplate).Mux)
+	openapiPath = renderPath((*PathTemplate).OpenAPI)
+	printfPath  = renderPath((*PathTemplate).Printf)
+)
+
+// httpPathVariables returns the variables of a path template.
+func httpPathVariables(path string) ([]*PathVariable, error) {
+	tpl, err := parsePathTemplate(path)
+	if err != nil {
+		return nil, err
+	}
+	return tpl.Variables(), nil
+}
+
+// pathVariableField returns the field of a message bound to a path variable, following the nested field paths.
+func pathVariableField(typeName string, v *PathVariable) (*Field, error) {
+	if model == nil {
+		return nil, fmt.Errorf("pathVariableField: no model")
+	}
+	msg := model.LookupMessage(typeName)
+	if msg == nil {
+		return nil, fmt.Errorf("pathVariableField: unknown message %s", typeName)
+	}
+	var field *Field
+	for _, name := range v.Fields {
+		if field != nil {
+			if msg = field.MessageType; msg == nil {
+				return nil, fmt.Errorf("pathVariableField: %s of %s is not a message", field.GetName(), v.FieldPath)
+			}
+		}
+		field = nil
+		for _, f := range msg.Fields {
+			if f.GetName() == name {
+				field = f
+			}
+		}
+		if field == nil {
+			return nil, fmt.Errorf("pathVariableField: %s has no field %s", msg.FullName, name)
+		}
+	}
+	return field, nil
+}
+
+// messageDescriptor is a message of the model or of the registry.
+type messageDescriptor interface {
+	FQMN() string
+	GetField() []*descriptor.FieldDescriptorProto
+}
+
+// urlHasVarsFromMessage reports whether a path template has a variable bound to a field of the message.
+func urlHasVarsFromMessage(path string, d messageDescriptor) bool {
+	variables, err := httpPathVariables(path)
+	if err != nil {
+		return false
+	}
+	for _, v := range variables {
+		if model != nil && model.LookupMessage(d.FQMN()) != nil {
+			if _, err := pathVariableField(d.FQMN(), v); err == nil {
+				return true
+			}
+			continue
+		}
+		for _, field := range d.GetField() {
+			if field.GetName() == v.Fields[0] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package pgghelpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePathTemplate(t *testing.T) {
+	tests := []struct {
+		path      string
+		variables []string // field path and pattern of the variables
+		verb      string
+		express   string
+		mux       string
+		openapi   string
+		printf    string
+	}{
+		{
+			path:    "/",
+			express: "/", mux: "/", openapi: "/", printf: "/",
+		},
+		{
+			path:    "/v1/users/",
+			express: "/v1/users/", mux: "/v1/users/", openapi: "/v1/users/", printf: "/v1/users/",
+		},
+		{
+			path:      "/v1/users/{user_id}",
+			variables: []string{"user_id", "*"},
+			express:   "/v1/users/:user_id", mux: "/v1/users/{user_id}", openapi: "/v1/users/{user_id}", printf: "/v1/users/%s",
+		},
+		{
+			path:      "/v1/users/{user.id}/",
+			variables: []string{"user.id", "*"},
+			express:   "/v1/users/:user_id/", mux: "/v1/users/{user.id}/", openapi: "/v1/users/{user.id}/", printf: "/v1/users/%s/",
+		},
+		{
+			path:      "/v1/{name=shelves/*}/books/{id}",
+			variables: []string{"name", "shelves/*", "id", "*"},
+			express:   "/v1/:name(shelves/[^/]+)/books/:id", mux: "/v1/{name:shelves/[^/]+}/books/{id}", openapi: "/v1/{name}/books/{id}", printf: "/v1/%s/books/%s",
+		},
+		{
+			path:      "/v1/{name=operations/**}:cancel",
+			variables: []string{"name", "operations/**"},
+			verb:      "cancel",
+			express:   `/v1/:name(operations/.+)\:cancel`, mux: "/v1/{name:operations/.+}:cancel", openapi: "/v1/{name}:cancel", printf: "/v1/%s:cancel",
+		},
+		{
+			path:    "/v1/*/files/**",
+			express: "/v1/*/files/*", mux: "/v1/{_1:[^/]+}/files/{_3:.+}", openapi: "/v1/*/files/**", printf: "/v1/*/files/**",
+		},
+		{
+			path:    "/:check",
+			verb:    "check",
+			express: `/\:check`, mux: "/:check", openapi: "/:check", printf: "/:check",
+		},
+		{
+			path:      "/v1/100%/{id}",
+			variables: []string{"id", "*"},
+			express:   "/v1/100%/:id", mux: "/v1/100%/{id}", openapi: "/v1/100%/{id}", printf: "/v1/100%%/%s",
+		},
+	}
+	for _, test := range tests {
+		tpl, err := parsePathTemplate(test.path)
+		if err != nil {
+			t.Errorf("parsePathTemplate(%q): %v", test.path, err)
+			continue
+		}
+		variables := []string{}
+		for _, v := range tpl.Variables() {
+			variables = append(variables, v.FieldPath, v.Pattern)
+		}
+		if len(test.variables) == 0 {
+			test.variables = []string{}
+		}
+		if !reflect.DeepEqual(variables, test.variables) {
+			t.Errorf("parsePathTemplate(%q) variables = %q, want %q", test.path, variables, test.variables)
+		}
+		if tpl.Verb != test.verb {
+			t.Errorf("parsePathTemplate(%q) verb = %q, want %q", test.path, tpl.Verb, test.verb)
+		}
+		for _, render := range []struct {
+			name      string
+			got, want string
+		}{
+			{"String", tpl.String(), test.path},
+			{"Express", tpl.Express(), test.express},
+			{"Mux", tpl.Mux(), test.mux},
+			{"OpenAPI", tpl.OpenAPI(), test.openapi},
+			{"Printf", tpl.Printf(), test.printf},
+		} {
+			if render.got != render.want {
+				t.Errorf("parsePathTemplate(%q).%s() = %q, want %q", test.path, render.name, render.got, render.want)
+			}
+		}
+	}
+}
+
+func TestParsePathTemplateErrors(t *testing.T) {
+	for _, path := range []string{
+		"",
+		"v1/users",
+		"//",
+		"/v1//users",
+		"/v1/users:",
+		"/v1/{id",
+		"/v1/{id}}",
+		"/v1/{user-id}",
+		"/v1/{name=shelves/{id}}",
+		"/v1/**/books",
+		"/v1/{name=**}/books",
+	} {
+		if tpl, err := parsePathTemplate(path); err == nil {
+			t.Errorf("parsePathTemplate(%q) = %q, want an error", path, tpl)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package pgghelpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+	"github.com/huandu/xstrings"
+)
+
+// TypeMapper maps the protobuf types to the types of a language, see RegisterTypeMapper.
+type TypeMapper interface {
+	// Scalar returns the type of a scalar, bytes and strings included
+	Scalar(t descriptor.FieldDescriptorProto_Type) string
+	// Named returns the type of a message or an enum
+	Named(name TypeName) string
+	Repeated(elem string) string
+	Map(key, value string) string
+	// Optional returns the type of a field that may be absent: messages, oneof members and proto2 optional fields
+	Optional(typ string) string
+}
+
+// TypeName is the name of a message or an enum, split in its package and its nested names, i.e: `Outer`, `Inner`.
+type TypeName struct {
+	Package string
+	Names   []string
+	Enum    bool
+}
+
+// scalarType is a row of the table of the types of the protobuf scalars in the builtin languages.
+type scalarType struct {
+	Python, Rust, Kotlin, Swift, Java, Haskell string
+}
+
+var scalarTypes = map[descriptor.FieldDescriptorProto_Type]scalarType{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   {Python: "float", Rust: "f64", Kotlin: "Double", Swift: "Double", Java: "double", Haskell: "Double"},
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    {Python: "float", Rust: "f32", Kotlin: "Float", Swift: "Float", Java: "float", Haskell: "Float"},
+	descriptor.FieldDescriptorProto_TYPE_INT64:    {Python: "int", Rust: "i64", Kotlin: "Long", Swift: "Int64", Java: "long", Haskell: "Int64"},
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   {Python: "int", Rust: "u64", Kotlin: "Long", Swift: "UInt64", Java: "long", Haskell: "Word64"},
+	descriptor.FieldDescriptorProto_TYPE_INT32:    {Python: "int", Rust: "i32", Kotlin: "Int", Swift: "Int32", Java: "int", Haskell: "Int32"},
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  {Python: "int", Rust: "u64", Kotlin: "Long", Swift: "UInt64", Java: "long", Haskell: "Word64"},
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  {Python: "int", Rust: "u32", Kotlin: "Int", Swift: "UInt32", Java: "int", Haskell: "Word32"},
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     {Python: "bool", Rust: "bool", Kotlin: "Boolean", Swift: "Bool", Java: "boolean", Haskell: "Bool"},
+	descriptor.FieldDescriptorProto_TYPE_STRING:   {Python: "str", Rust: "String", Kotlin: "String", Swift: "String", Java: "String", Haskell: "Text"},
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    {Python: "bytes", Rust: "Vec<u8>", Kotlin: "ByteString", Swift: "Data", Java: "ByteString", Haskell: "ByteString"},
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   {Python: "int", Rust: "u32", Kotlin: "Int", Swift: "UInt32", Java: "int", Haskell: "Word32"},
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: {Python: "int", Rust: "i32", Kotlin: "Int", Swift: "Int32", Java: "int", Haskell: "Int32"},
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: {Python: "int", Rust: "i64", Kotlin: "Long", Swift: "Int64", Java: "long", Haskell: "Int64"},
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   {Python: "int", Rust: "i32", Kotlin: "Int", Swift: "Int32", Java: "int", Haskell: "Int32"},
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   {Python: "int", Rust: "i64", Kotlin: "Long", Swift: "Int64", Java: "long", Haskell: "Int64"},
+}
+
+var typeMappers = map[string]TypeMapper{
+	"python":  pythonMapper{},
+	"rust":    rustMapper{},
+	"kotlin":  kotlinMapper{},
+	"swift":   swiftMapper{},
+	"java":    javaMapper{},
+	"haskell": haskellMapper{},
+}
+
+// RegisterTypeMapper adds or replaces the mapper of a language, available in the templates with `fieldType "<lang>" .`.
+func RegisterTypeMapper(lang string, mapper TypeMapper) {
+	typeMappers[lang] = mapper
+}
+
+// fieldType returns the type of a field in the given language.
+func fieldType(lang string, f *descriptor.FieldDescriptorProto) (string, error) {
+	mapper, found := typeMappers[lang]
+	if !found {
+		return "", fmt.Errorf("no type mapper for %q", lang)
+	}
+	return mapFieldType(mapper, f), nil
+}
+
+func mapFieldType(mapper TypeMapper, f *descriptor.FieldDescriptorProto) string {
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		return mapper.Map(mapElemType(mapper, entry.Fields[0].FieldDescriptorProto), mapElemType(mapper, entry.Fields[1].FieldDescriptorProto))
+	}
+	typ := mapElemType(mapper, f)
+	switch {
+	case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return mapper.Repeated(typ)
+	case fieldMayBeAbsent(f):
+		return mapper.Optional(typ)
+	}
+	return typ
+}
+
+func fieldMayBeAbsent(f *descriptor.FieldDescriptorProto) bool {
+	switch {
+	case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return false
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE, f.OneofIndex != nil:
+		return true
+	}
+	field := model.lookupField(f)
+	return f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_OPTIONAL && field != nil && field.Message.File.GetSyntax() != "proto3"
+}
+
+func mapElemType(mapper TypeMapper, f *descriptor.FieldDescriptorProto) string {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return mapper.Named(typeNameOf(f.GetTypeName()))
+	}
+	return mapper.Scalar(f.GetType())
+}
+
+// typeNameOf splits a fully qualified type name using the model to tell the package from the parent messages.
+func typeNameOf(typeName string) TypeName {
+	name := TypeName{}
+	if model != nil {
+		msg := model.LookupMessage(typeName)
+		if enum := model.LookupEnum(typeName); enum != nil {
+			name.Package = enum.File.GetPackage()
+			name.Names = []string{enum.GetName()}
+			name.Enum = true
+			msg = enum.Parent
+		}
+		for ; msg != nil; msg = msg.Parent {
+			name.Package = msg.File.GetPackage()
+			name.Names = append([]string{msg.GetName()}, name.Names...)
+		}
+	}
+	if len(name.Names) == 0 {
+		name.Names = []string{shortType(typeName)}
+	}
+	return name
+}
+
+type pythonMapper struct{}
+
+func (pythonMapper) Scalar(t descriptor.FieldDescriptorProto_Type) string {
+	return scalarTypes[t].Python
+}
+func (pythonMapper) Named(name TypeName) string   { return strings.Join(name.Names, ".") }
+func (pythonMapper) Repeated(elem string) string  { return "List[" + elem + "]" }
+func (pythonMapper) Map(key, value string) string { return "Dict[" + key + ", " + value + "]" }
+func (pythonMapper) Optional(typ string) string   { return "Optional[" + typ + "]" }
+
+// rustMapper follows prost: nested types live in a snake_case module of their parent and enums are i32.
+type rustMapper struct{}
+
+func (rustMapper) Scalar(t descriptor.FieldDescriptorProto_Type) string { return scalarTypes[t].Rust }
+func (rustMapper) Named(name TypeName) string {
+	if name.Enum {
+		return "i32"
+	}
+	parts := make([]string, len(name.Names))
+	for i, n := range name.Names {
+		if i == len(name.Names)-1 {
+			parts[i] = generator.CamelCase(n)
+		} else {
+			parts[i] = xstrings.ToSnakeCase(n)
+		}
+	}
+	return strings.Join(parts, "::")
+}
+func (rustMapper) Repeated(elem string) string  { return "Vec<" + elem + ">" }
+func (rustMapper) Map(key, value string) string { return "HashMap<" + key + ", " + value + ">" }
+func (rustMapper) Optional(typ string) string   { return "Option<" + typ + ">" }
+
+type kotlinMapper struct{}
+
+func (kotlinMapper) Scalar(t descriptor.FieldDescriptorProto_Type) string {
+	return scalarTypes[t].Kotlin
+}
+func (kotlinMapper) Named(name TypeName) string   { return strings.Join(name.Names, ".") }
+func (kotlinMapper) Repeated(elem string) string  { return "List<" + elem + ">" }
+func (kotlinMapper) Map(key, value string) string { return "Map<" + key + ", " + value + ">" }
+func (kotlinMapper) Optional(typ string) string   { return typ + "?" }
+
+// swiftMapper follows swift-protobuf: top-level types are prefixed by their package, i.e: `Shop_Product.Sub`.
+type swiftMapper struct{}
+
+func (swiftMapper) Scalar(t descriptor.FieldDescriptorProto_Type) string { return scalarTypes[t].Swift }
+func (swiftMapper) Named(name TypeName) string {
+	prefix := ""
+	for _, part := range strings.Split(name.Package, ".") {
+		if part != "" {
+			prefix += generator.CamelCase(part) + "_"
+		}
+	}
+	return prefix + strings.Join(name.Names, ".")
+}
+func (swiftMapper) Repeated(elem string) string  { return "[" + elem + "]" }
+func (swiftMapper) Map(key, value string) string { return "[" + key + ": " + value + "]" }
+func (swiftMapper) Optional(typ string) string   { return typ + "?" }
+
+// javaMapper follows protobuf-java, the absent fields are told by their `has` methods.
+type javaMapper struct{}
+
+var javaBoxedTypes = map[string]string{
+	"boolean": "Boolean",
+	"double":  "Double",
+	"float":   "Float",
+	"int":     "Integer",
+	"long":    "Long",
+}
+
+func javaBoxed(typ string) string {
+	if boxed, found := javaBoxedTypes[typ]; found {
+		return boxed
+	}
+	return typ
+}
+
+func (javaMapper) Scalar(t descriptor.FieldDescriptorProto_Type) string { return scalarTypes[t].Java }
+func (javaMapper) Named(name TypeName) string                           { return strings.Join(name.Names, ".") }
+func (javaMapper) Repeated(elem string) string                          { return "List<" + javaBoxed(elem) + ">" }
+func (javaMapper) Map(key, value string) string {
+	return "Map<" + javaBoxed(key) + ", " + javaBoxed(value) + ">"
+}
+func (javaMapper) Optional(typ string) string { return typ }
+
+// haskellMapper follows proto-lens: nested types are joined by a quote, i.e: `Product'Sub`, and
+// qualified by the module alias Pkg.
+type haskellMapper struct {
+	Pkg string
+}
+
+func (haskellMapper) Scalar(t descriptor.FieldDescriptorProto_Type) string {
+	return scalarTypes[t].Haskell
+}
+func (m haskellMapper) Named(name TypeName) string {
+	if m.Pkg == "" {
+		return strings.Join(name.Names, "'")
+	}
+	return m.Pkg + "." + strings.Join(name.Names, "'")
+}
+func (haskellMapper) Repeated(elem string) string  { return "[" + elem + "]" }
+func (haskellMapper) Map(key, value string) string { return "Map " + key + " " + value }
+func (haskellMapper) Optional(typ string) string   { return typ }
+
+func pythonType(f *descriptor.FieldDescriptorProto) string {
+	return mapFieldType(typeMappers["python"], f)
+}
+func rustType(f *descriptor.FieldDescriptorProto) string { return mapFieldType(typeMappers["rust"], f) }
+func kotlinType(f *descriptor.FieldDescriptorProto) string {
+	return mapFieldType(typeMappers["kotlin"], f)
+}
+func swiftType(f *descriptor.FieldDescriptorProto) string {
+	return mapFieldType(typeMappers["swift"], f)
+}
+func javaType(f *descriptor.FieldDescriptorProto) string { return mapFieldType(typeMappers["java"], f) }
+
+// haskellType returns the type of a field, the messages and enums being qualified by the module alias pkg.
+func haskellType(pkg string, f *descriptor.FieldDescriptorProto) string {
+	return mapFieldType(haskellMapper{Pkg: pkg}, f)
+}
@@ -0,0 +1,89 @@
+package pgghelpers
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestFieldTypeScalars(t *testing.T) {
+	languages := []string{"python", "rust", "kotlin", "swift", "java", "haskell"}
+	tests := []struct {
+		typ   descriptor.FieldDescriptorProto_Type
+		types []string
+	}{
+		{descriptor.FieldDescriptorProto_TYPE_DOUBLE, []string{"float", "f64", "Double", "Double", "double", "Double"}},
+		{descriptor.FieldDescriptorProto_TYPE_FLOAT, []string{"float", "f32", "Float", "Float", "float", "Float"}},
+		{descriptor.FieldDescriptorProto_TYPE_INT64, []string{"int", "i64", "Long", "Int64", "long", "Int64"}},
+		{descriptor.FieldDescriptorProto_TYPE_UINT64, []string{"int", "u64", "Long", "UInt64", "long", "Word64"}},
+		{descriptor.FieldDescriptorProto_TYPE_INT32, []string{"int", "i32", "Int", "Int32", "int", "Int32"}},
+		{descriptor.FieldDescriptorProto_TYPE_FIXED64, []string{"int", "u64", "Long", "UInt64", "long", "Word64"}},
+		{descriptor.FieldDescriptorProto_TYPE_FIXED32, []string{"int", "u32", "Int", "UInt32", "int", "Word32"}},
+		{descriptor.FieldDescriptorProto_TYPE_BOOL, []string{"bool", "bool", "Boolean", "Bool", "boolean", "Bool"}},
+		{descriptor.FieldDescriptorProto_TYPE_STRING, []string{"str", "String", "String", "String", "String", "Text"}},
+		{descriptor.FieldDescriptorProto_TYPE_BYTES, []string{"bytes", "Vec<u8>", "ByteString", "Data", "ByteString", "ByteString"}},
+		{descriptor.FieldDescriptorProto_TYPE_UINT32, []string{"int", "u32", "Int", "UInt32", "int", "Word32"}},
+		{descriptor.FieldDescriptorProto_TYPE_SFIXED32, []string{"int", "i32", "Int", "Int32", "int", "Int32"}},
+		{descriptor.FieldDescriptorProto_TYPE_SFIXED64, []string{"int", "i64", "Long", "Int64", "long", "Int64"}},
+		{descriptor.FieldDescriptorProto_TYPE_SINT32, []string{"int", "i32", "Int", "Int32", "int", "Int32"}},
+		{descriptor.FieldDescriptorProto_TYPE_SINT64, []string{"int", "i64", "Long", "Int64", "long", "Int64"}},
+	}
+
+	tested := make(map[descriptor.FieldDescriptorProto_Type]bool)
+	for _, test := range tests {
+		tested[test.typ] = true
+		field := &descriptor.FieldDescriptorProto{
+			Name:  stringPtr("field"),
+			Type:  test.typ.Enum(),
+			Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		for i, lang := range languages {
+			typ, err := fieldType(lang, field)
+			if err != nil {
+				t.Fatalf("fieldType(%q, %s): %v", lang, test.typ, err)
+			}
+			if typ != test.types[i] {
+				t.Errorf("fieldType(%q, %s) = %q, want %q", lang, test.typ, typ, test.types[i])
+			}
+		}
+	}
+
+	for value := range descriptor.FieldDescriptorProto_Type_name {
+		typ := descriptor.FieldDescriptorProto_Type(value)
+		switch typ {
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP, descriptor.FieldDescriptorProto_TYPE_ENUM:
+			continue
+		}
+		if !tested[typ] {
+			t.Errorf("scalar %s is not tested", typ)
+		}
+		if _, found := scalarTypes[typ]; !found {
+			t.Errorf("scalar %s is missing from scalarTypes", typ)
+		}
+	}
+}
+
+func TestFieldTypeRepeatedScalars(t *testing.T) {
+	field := &descriptor.FieldDescriptorProto{
+		Name:  stringPtr("field"),
+		Type:  descriptor.FieldDescriptorProto_TYPE_INT32.Enum(),
+		Label: descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+	}
+	tests := map[string]string{
+		"python":  "List[int]",
+		"rust":    "Vec<i32>",
+		"kotlin":  "List<Int>",
+		"swift":   "[Int32]",
+		"java":    "List<Integer>",
+		"haskell": "[Int32]",
+	}
+	for lang, want := range tests {
+		if typ, err := fieldType(lang, field); err != nil || typ != want {
+			t.Errorf("fieldType(%q, repeated int32) = %q, %v, want %q", lang, typ, err, want)
+		}
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,126 @@
+package pgghelpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/huandu/xstrings"
+)
+
+// tsWellKnownTypes are the TypeScript types of the proto3 JSON mapping of the well-known types.
+var tsWellKnownTypes = map[string]string{
+	".google.protobuf.Any":         `{ "@type": string; [key: string]: any }`,
+	".google.protobuf.BoolValue":   "boolean",
+	".google.protobuf.BytesValue":  "string",
+	".google.protobuf.DoubleValue": "number",
+	".google.protobuf.Duration":    "string",
+	".google.protobuf.Empty":       "{}",
+	".google.protobuf.FieldMask":   "string",
+	".google.protobuf.FloatValue":  "number",
+	".google.protobuf.Int32Value":  "number",
+	".google.protobuf.Int64Value":  "string",
+	".google.protobuf.ListValue":   "any[]",
+	".google.protobuf.NullValue":   "null",
+	".google.protobuf.StringValue": "string",
+	".google.protobuf.Struct":      "{ [key: string]: any }",
+	".google.protobuf.Timestamp":   "string",
+	".google.protobuf.UInt32Value": "number",
+	".google.protobuf.UInt64Value": "string",
+	".google.protobuf.Value":       "any",
+}
+
+// tsType returns the TypeScript type of the proto3 JSON mapping of a field.
+// 64-bit integers and bytes are strings, messages and enums are named after their nested name, i.e: `Outer_Inner`.
+func tsType(f *descriptor.FieldDescriptorProto) string {
+	return tsFieldType(f, func(string) string { return "" })
+}
+
+// tsTypeWithNamespace is like tsType, the messages and enums being prefixed by their package, i.e: `package.Outer_Inner`.
+func tsTypeWithNamespace(f *descriptor.FieldDescriptorProto) string {
+	return tsFieldType(f, func(typeName string) string {
+		if file := declaringFile(typeName); file != nil && file.GetPackage() != "" {
+			return file.GetPackage() + "."
+		}
+		return ""
+	})
+}
+
+// tsTypeName returns the TypeScript name of a message or an enum from its fully qualified name, i.e: `Outer_Inner`.
+func tsTypeName(typeName string) string {
+	return goTypeName(typeName)
+}
+
+func tsFieldType(f *descriptor.FieldDescriptorProto, namespace func(typeName string) string) string {
+	if entry := mapEntry(f); entry != nil && len(entry.Fields) == 2 {
+		// JSON object keys are always strings
+		return fmt.Sprintf("{ [key: string]: %s }", tsFieldType(entry.Fields[1].FieldDescriptorProto, namespace))
+	}
+
+	var typ string
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		typ = "number"
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		typ = "string"
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		typ = "boolean"
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES:
+		typ = "string"
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if wkt, found := tsWellKnownTypes[f.GetTypeName()]; found {
+			typ = wkt
+		} else {
+			typ = namespace(f.GetTypeName()) + tsTypeName(f.GetTypeName())
+		}
+	default:
+		typ = "any"
+	}
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		if strings.ContainsAny(typ, " |") {
+			return "Array<" + typ + ">"
+		}
+		return typ + "[]"
+	}
+	return typ
+}
+
+// tsOptional reports whether a field may be absent: messages, oneof members and proto2 optional fields.
+func tsOptional(f *descriptor.FieldDescriptorProto) bool {
+	return fieldMayBeAbsent(f)
+}
+
+// tsField returns the declaration of a field in a TypeScript interface, i.e: `createdAt?: string`.
+func tsField(f *descriptor.FieldDescriptorProto) string {
+	name := f.GetJsonName()
+	if name == "" {
+		name = xstrings.FirstRuneToLower(xstrings.ToCamelCase(f.GetName()))
+	}
+	if tsOptional(f) {
+		return name + "?: " + tsType(f)
+	}
+	return name + ": " + tsType(f)
+}
+
+// tsEnumUnion returns the union of the names of the values of an enum, i.e: `"RED" | "BLUE"`,
+// to declare the enum as a string union type.
+func tsEnumUnion(e *descriptor.EnumDescriptorProto) string {
+	values := make([]string, 0, len(e.GetValue()))
+	for _, value := range e.GetValue() {
+		values = append(values, fmt.Sprintf("%q", value.GetName()))
+	}
+	return strings.Join(values, " | ")
+}
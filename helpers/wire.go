@@ -0,0 +1,108 @@
+package pgghelpers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// rawField is a field of an encoded message, read without its descriptor.
+type rawField struct {
+	Number   int32
+	WireType int
+	// Varint is the value of the varint and fixed fields
+	Varint uint64
+	// Bytes is the value of the length-delimited fields, or the encoded fields of a group
+	Bytes []byte
+}
+
+// readRawFields decodes the fields of an encoded message, i.e: the unrecognized fields of a descriptor
+// generated by an older version of protoc-gen-go.
+func readRawFields(raw []byte) ([]rawField, error) {
+	fields := []rawField{}
+	for len(raw) > 0 {
+		field, n, err := readRawField(raw)
+		if err != nil {
+			return nil, err
+		}
+		if field.WireType == proto.WireEndGroup {
+			return nil, fmt.Errorf("field %d: unexpected end of group", field.Number)
+		}
+		raw = raw[n:]
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// readRawField decodes the first field of raw and returns its encoded size. A group is read up to
+// its end-group tag, nested groups included, and an end-group tag is returned as a field without value.
+func readRawField(raw []byte) (rawField, int, error) {
+	key, n := proto.DecodeVarint(raw)
+	if n == 0 {
+		return rawField{}, 0, fmt.Errorf("invalid field key")
+	}
+	field := rawField{Number: int32(key >> 3), WireType: int(key & 7)}
+	raw = raw[n:]
+	switch field.WireType {
+	case proto.WireVarint:
+		var m int
+		if field.Varint, m = proto.DecodeVarint(raw); m == 0 {
+			return field, 0, fmt.Errorf("field %d: invalid varint", field.Number)
+		}
+		return field, n + m, nil
+	case proto.WireFixed64:
+		if len(raw) < 8 {
+			return field, 0, fmt.Errorf("field %d: truncated fixed64", field.Number)
+		}
+		field.Varint = binary.LittleEndian.Uint64(raw)
+		return field, n + 8, nil
+	case proto.WireFixed32:
+		if len(raw) < 4 {
+			return field, 0, fmt.Errorf("field %d: truncated fixed32", field.Number)
+		}
+		field.Varint = uint64(binary.LittleEndian.Uint32(raw))
+		return field, n + 4, nil
+	case proto.WireBytes:
+		size, m := proto.DecodeVarint(raw)
+		if m == 0 || uint64(len(raw)-m) < size {
+			return field, 0, fmt.Errorf("field %d: truncated bytes", field.Number)
+		}
+		field.Bytes = raw[m : m+int(size)]
+		return field, n + m + int(size), nil
+	case proto.WireStartGroup:
+		for offset := 0; offset < len(raw); {
+			nested, m, err := readRawField(raw[offset:])
+			if err != nil {
+				return field, 0, fmt.Errorf("group %d: %v", field.Number, err)
+			}
+			if nested.WireType == proto.WireEndGroup {
+				if nested.Number != field.Number {
+					return field, 0, fmt.Errorf("group %d: unexpected end of group %d", field.Number, nested.Number)
+				}
+				field.Bytes = raw[:offset]
+				return field, n + offset + m, nil
+			}
+			offset += m
+		}
+		return field, 0, fmt.Errorf("group %d: truncated group", field.Number)
+	case proto.WireEndGroup:
+		return field, n, nil
+	}
+	return field, 0, fmt.Errorf("field %d: unsupported wire type %d", field.Number, field.WireType)
+}
+
+// rawVarint returns the value of the last occurrence of a varint field, as protobuf does for singular fields.
+func rawVarint(raw []byte, number int32) (uint64, bool) {
+	fields, err := readRawFields(raw)
+	if err != nil {
+		return 0, false
+	}
+	value, found := uint64(0), false
+	for _, field := range fields {
+		if field.Number == number && field.WireType == proto.WireVarint {
+			value, found = field.Varint, true
+		}
+	}
+	return value, found
+}
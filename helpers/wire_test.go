@@ -0,0 +1,81 @@
+package pgghelpers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// wireKey returns the encoded key of a field.
+func wireKey(number int32, wireType int) []byte {
+	return proto.EncodeVarint(uint64(number)<<3 | uint64(wireType))
+}
+
+func join(parts ...[]byte) []byte {
+	raw := []byte{}
+	for _, part := range parts {
+		raw = append(raw, part...)
+	}
+	return raw
+}
+
+func TestReadRawFields(t *testing.T) {
+	group := join(wireKey(1, proto.WireVarint), []byte{0x2a}, wireKey(2, proto.WireBytes), []byte{2, 'h', 'i'})
+	nested := join(wireKey(3, proto.WireStartGroup), wireKey(1, proto.WireFixed32), []byte{1, 0, 0, 0}, wireKey(3, proto.WireEndGroup))
+	tests := []struct {
+		name   string
+		raw    []byte
+		fields []rawField
+	}{
+		{"empty", []byte{}, []rawField{}},
+		{"varint", join(wireKey(1, proto.WireVarint), []byte{0xac, 0x02}), []rawField{{Number: 1, WireType: proto.WireVarint, Varint: 300}}},
+		{"fixed64", join(wireKey(2, proto.WireFixed64), []byte{1, 2, 0, 0, 0, 0, 0, 0x80}), []rawField{{Number: 2, WireType: proto.WireFixed64, Varint: 0x8000000000000201}}},
+		{"fixed32", join(wireKey(3, proto.WireFixed32), []byte{1, 2, 0, 0}), []rawField{{Number: 3, WireType: proto.WireFixed32, Varint: 0x201}}},
+		{"bytes", join(wireKey(4, proto.WireBytes), []byte{3, 'a', 'b', 'c'}), []rawField{{Number: 4, WireType: proto.WireBytes, Bytes: []byte("abc")}}},
+		{
+			"group",
+			join(wireKey(5, proto.WireStartGroup), group, wireKey(5, proto.WireEndGroup), wireKey(6, proto.WireVarint), []byte{1}),
+			[]rawField{{Number: 5, WireType: proto.WireStartGroup, Bytes: group}, {Number: 6, WireType: proto.WireVarint, Varint: 1}},
+		},
+		{
+			"nested group",
+			join(wireKey(5, proto.WireStartGroup), nested, wireKey(5, proto.WireEndGroup)),
+			[]rawField{{Number: 5, WireType: proto.WireStartGroup, Bytes: nested}},
+		},
+		{
+			"empty group",
+			join(wireKey(5, proto.WireStartGroup), wireKey(5, proto.WireEndGroup)),
+			[]rawField{{Number: 5, WireType: proto.WireStartGroup, Bytes: []byte{}}},
+		},
+	}
+	for _, test := range tests {
+		fields, err := readRawFields(test.raw)
+		if err != nil {
+			t.Errorf("%s: readRawFields(%x): %v", test.name, test.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s: readRawFields(%x) = %+v, want %+v", test.name, test.raw, fields, test.fields)
+		}
+	}
+}
+
+func TestReadRawFieldsErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"invalid key":       {0x80},
+		"truncated varint":  join(wireKey(1, proto.WireVarint), []byte{0x80}),
+		"truncated fixed64": join(wireKey(1, proto.WireFixed64), []byte{1, 2, 3}),
+		"truncated fixed32": join(wireKey(1, proto.WireFixed32), []byte{1}),
+		"truncated bytes":   join(wireKey(1, proto.WireBytes), []byte{3, 'a'}),
+		"unclosed group":    join(wireKey(1, proto.WireStartGroup), wireKey(2, proto.WireVarint), []byte{1}),
+		"mismatched group":  join(wireKey(1, proto.WireStartGroup), wireKey(2, proto.WireEndGroup)),
+		"unopened group":    wireKey(1, proto.WireEndGroup),
+		"unknown wire type": wireKey(1, 6),
+	}
+	for name, raw := range tests {
+		if fields, err := readRawFields(raw); err == nil {
+			t.Errorf("%s: readRawFields(%x) = %+v, want an error", name, raw, fields)
+		}
+	}
+}